@@ -0,0 +1,328 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ARTICLE_MIN_TEXT is the shortest paragraph text, in bytes, that counts toward a block score
+const ARTICLE_MIN_TEXT = 25
+
+var (
+	_articlePositive = regexp.MustCompile(`(?i)article|body|content|entry|hentry|main|page|post|text|blog|story`)
+	_articleNegative = regexp.MustCompile(`(?i)comment|combx|contact|footer|footnote|masthead|media|meta|nav|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|social|tags|tool|widget|banner|breadcrumb|menu|\bad-|\bads\b`)
+	_articleByline   = regexp.MustCompile(`(?i)byline|author|writtenby`)
+	// containers that never hold the main content
+	_articleSkipTags = map[string]bool{"nav": true, "aside": true, "footer": true, "header": true, "form": true, "button": true, "select": true}
+	// paragraph like elements whose text is scored
+	_articleScoreTags = map[string]bool{"p": true, "pre": true, "td": true, "blockquote": true}
+)
+
+//
+// Article def
+// The main content of a page as found by Article, with its metadata.
+//
+type Article struct {
+	Node      *DOMNode
+	Title     string
+	Byline    string
+	LeadImage string
+	Published time.Time
+}
+
+//
+// the per node measures gathered in one pass
+//
+type _articleStats struct {
+	textLength map[*DOMNode]int
+	linkLength map[*DOMNode]int
+	scores     map[*DOMNode]float64
+}
+
+//
+// Article : Find the main content node by text density, link density and semantic tags
+// Returns nil when the document has no body text to speak of.
+//
+func (id *DOM) Article() *Article {
+	root := id.RootNode()
+	if root == nil {
+		return nil
+	}
+
+	stats := &_articleStats{textLength: map[*DOMNode]int{}, linkLength: map[*DOMNode]int{}, scores: map[*DOMNode]float64{}}
+	stats.measure(root, false)
+	stats.score(root)
+
+	var top *DOMNode
+	topScore := 0.0
+	for node, score := range stats.scores {
+		// links dilute the score, a menu of long link texts is not content
+		if length := stats.textLength[node]; length > 0 {
+			score *= 1 - float64(stats.linkLength[node])/float64(length)
+		}
+		if score > topScore || (score == topScore && top != nil && node.Index < top.Index) {
+			top, topScore = node, score
+		}
+	}
+	if top == nil {
+		return nil
+	}
+
+	// a semantic container enclosing the winner is the better boundary
+	for node := top.Parent; node != nil && node != root; node = node.Parent {
+		if node.Tag == "article" || node.Tag == "main" {
+			top = node
+			break
+		}
+	}
+
+	article := &Article{Node: top}
+	article.Title = id._articleTitle()
+	article.Byline = id._articleByline()
+	article.LeadImage = id._articleLeadImage(top)
+	article.Published = id._articlePublished()
+
+	return article
+}
+
+//
+// Article: Total and link text lengths of every element, skipping non reader content
+//
+func (id *_articleStats) measure(node *DOMNode, inLink bool) (textLength int, linkLength int) {
+	if _domNonReaderTags[node.Tag] {
+		return 0, 0
+	}
+
+	inLink = inLink || node.Tag == "a"
+	for _, child := range node.ChildNodes {
+		if child.IsText() {
+			length := len(strings.TrimSpace(child.Data))
+			textLength += length
+			if inLink {
+				linkLength += length
+			}
+		} else if child.IsElement() {
+			childText, childLinks := id.measure(child, inLink)
+			textLength += childText
+			linkLength += childLinks
+		}
+	}
+
+	id.textLength[node] = textLength
+	id.linkLength[node] = linkLength
+
+	return textLength, linkLength
+}
+
+//
+// Article: Credit each paragraph score to its parent and half to its grandparent
+//
+func (id *_articleStats) score(node *DOMNode) {
+	if _articleSkipTags[node.Tag] || _articleUnlikely(node) {
+		return
+	}
+
+	if _articleScoreTags[node.Tag] && id.textLength[node] >= ARTICLE_MIN_TEXT && node.Parent != nil {
+		text := node.ReaderText()
+		score := 1 + float64(strings.Count(text, ","))
+		if bonus := float64(len(text)) / 100; bonus < 3 {
+			score += bonus
+		} else {
+			score += 3
+		}
+
+		id.credit(node.Parent, score)
+		if node.Parent.Parent != nil {
+			id.credit(node.Parent.Parent, score/2)
+		}
+	}
+
+	for _, child := range node.Children {
+		id.score(child)
+	}
+}
+
+func (id *_articleStats) credit(node *DOMNode, score float64) {
+	if _, ok := id.scores[node]; !ok {
+		id.scores[node] = _articleBaseScore(node)
+	}
+	id.scores[node] += score
+}
+
+//
+// Article: The starting score of a candidate from its tag, class and id
+//
+func _articleBaseScore(node *DOMNode) (score float64) {
+	switch node.Tag {
+	case "article", "main":
+		score = 10
+	case "div", "section":
+		score = 5
+	case "pre", "td", "blockquote":
+		score = 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		score = -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		score = -5
+	}
+
+	for _, value := range []string{node.Attr("class"), node.Attr("id")} {
+		if len(value) == 0 {
+			continue
+		}
+		if _articlePositive.MatchString(value) {
+			score += 25
+		}
+		if _articleNegative.MatchString(value) {
+			score -= 25
+		}
+	}
+
+	return score
+}
+
+//
+// Article: Navigation, comments and other chrome identified by class or id
+//
+func _articleUnlikely(node *DOMNode) bool {
+	if node.Tag == "body" || node.Tag == "article" || node.Tag == "main" {
+		return false
+	}
+	hint := node.Attr("class") + " " + node.Attr("id")
+	if len(strings.TrimSpace(hint)) == 0 {
+		return false
+	}
+
+	return _articleNegative.MatchString(hint) && !_articlePositive.MatchString(hint)
+}
+
+//
+// Article: The og:title, else the document title without the site suffix, else the first h1
+//
+func (id *DOM) _articleTitle() string {
+	if title := id._metaContent("og:title"); len(title) > 0 {
+		return title
+	}
+
+	if titles := id.Find("title", nil); len(titles) > 0 {
+		title := strings.TrimSpace(titles[0].Text())
+		for _, separator := range []string{" | ", " - ", " :: ", " — "} {
+			if idx := strings.LastIndex(title, separator); idx > 0 {
+				title = strings.TrimSpace(title[:idx])
+				break
+			}
+		}
+		if len(title) > 0 {
+			return title
+		}
+	}
+
+	if headings := id.Find("h1", nil); len(headings) > 0 {
+		return headings[0].ReaderText()
+	}
+
+	return ""
+}
+
+//
+// Article: The author meta, else a rel=author link, else a short byline classed element
+//
+func (id *DOM) _articleByline() string {
+	if author := id._metaContent("author"); len(author) > 0 {
+		return author
+	}
+
+	if authors := id.Find("a", DOMNodeAttributes{"rel": "author"}); len(authors) > 0 {
+		return authors[0].ReaderText()
+	}
+
+	for _, node := range id.document {
+		if node.IsElement() && _articleByline.MatchString(node.Attr("class")+" "+node.Attr("id")+" "+node.Attr("itemprop")) {
+			if text := node.ReaderText(); len(text) > 0 && len(text) < 100 {
+				return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text), "By "))
+			}
+		}
+	}
+
+	return ""
+}
+
+//
+// Article: The og:image, else the first image of the content
+//
+func (id *DOM) _articleLeadImage(top *DOMNode) string {
+	if image := id._metaContent("og:image"); len(image) > 0 {
+		return image
+	}
+
+	if images := id.ChildFind(top, "img", nil); len(images) > 0 {
+		return images[0].Attr("src")
+	}
+
+	return ""
+}
+
+//
+// Article: The publish date from the article meta, a datePublished item or the first time element
+//
+func (id *DOM) _articlePublished() (result time.Time) {
+	candidates := []string{
+		id._metaContent("article:published_time"),
+		id._metaContent("datePublished"),
+		id._metaContent("date"),
+		id._metaContent("pubdate"),
+	}
+	for _, node := range id.Find("time", nil) {
+		candidates = append(candidates, node.Attr("datetime"))
+	}
+
+	for _, value := range candidates {
+		if len(value) == 0 {
+			continue
+		}
+		if published, err := ParseSitemapTime(value); err == nil {
+			return published
+		}
+	}
+
+	return result
+}
+
+//
+// DOM: The content of the first meta with the given property, name or itemprop
+//
+func (id *DOM) _metaContent(name string) string {
+	for _, key := range []string{"property", "name", "itemprop"} {
+		if nodes := id.Find("meta", DOMNodeAttributes{key: name}); len(nodes) > 0 {
+			if content := strings.TrimSpace(nodes[0].Attr("content")); len(content) > 0 {
+				return content
+			}
+		}
+	}
+
+	return ""
+}
+
+//
+// Text : The article laid out as plain text
+//
+func (id *Article) Text() string {
+	return id.Node.PlainText()
+}
+
+//
+// Markdown : The article as Markdown, headed by its title
+//
+func (id *Article) Markdown() string {
+	markdown := id.Node.Markdown()
+	if len(id.Title) > 0 && !strings.HasPrefix(markdown, "# "+id.Title+"\n") && markdown != "# "+id.Title {
+		markdown = "# " + id.Title + "\n\n" + markdown
+	}
+
+	return markdown
+}
@@ -0,0 +1,71 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testArticleHTML = `<!DOCTYPE html>
+<html><head>
+<title>Rivers of the North | Example News</title>
+<meta name="author" content="Jane Doe">
+<meta property="article:published_time" content="2016-03-01T09:30:00Z">
+<script>var tracking = "a, b, c, d, e, f, g, h";</script>
+</head><body>
+<div id="nav" class="menu"><ul><li><a href="/">Home</a></li><li><a href="/world">World news, politics, sports and more</a></li></ul></div>
+<div class="wrapper">
+  <div class="story-body">
+    <h1>Rivers of the North</h1>
+    <p>The northern rivers, long ignored by surveyors, turn out to carry more water than expected, according to a new study.</p>
+    <p>Researchers measured flow at forty stations, over three winters, and found the spring melt arriving weeks earlier.</p>
+    <img src="/img/river.jpg">
+    <h2>What it means</h2>
+    <ul><li>Earlier floods</li><li>Drier summers</li></ul>
+    <p>Local officials, who funded part of the work, say planning will have to change, and soon.</p>
+  </div>
+  <div class="sidebar"><p>Related: a long list of other stories, with commas, commas, and more commas, to tempt the scorer.</p></div>
+</div>
+<div class="footer"><p>Copyright, all rights reserved, contact us, privacy policy, terms of use.</p></div>
+</body></html>`
+
+func TestArticle(t *testing.T) {
+	d := NewDOM()
+	d.SetContents(testArticleHTML)
+
+	article := d.Article()
+	if article == nil {
+		t.Fatal("failed to find the article")
+	}
+	if article.Node.Attr("class") != "story-body" {
+		t.Errorf("expected the story body, got %s %s", article.Node.Tag, article.Node.Attr("class"))
+	}
+	if article.Title != "Rivers of the North" || article.Byline != "Jane Doe" || article.LeadImage != "/img/river.jpg" {
+		t.Errorf("unexpected metadata %+v", article)
+	}
+	if !article.Published.Equal(time.Date(2016, 3, 1, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected publish date %s", article.Published)
+	}
+
+	text := article.Text()
+	if !strings.HasPrefix(text, "Rivers of the North\n\nThe northern rivers") || strings.Contains(text, "Copyright") || strings.Contains(text, "Related") {
+		t.Errorf("unexpected text\n%s", text)
+	}
+
+	markdown := article.Markdown()
+	for _, expected := range []string{"# Rivers of the North\n\nThe northern rivers", "## What it means", "- Earlier floods\n- Drier summers"} {
+		if !strings.Contains(markdown, expected) {
+			t.Errorf("expected %q in\n%s", expected, markdown)
+		}
+	}
+
+	empty := NewDOM()
+	empty.SetContents("<html><body><a href='/'>home</a></body></html>")
+	if empty.Article() != nil {
+		t.Errorf("expected no article")
+	}
+}
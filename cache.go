@@ -0,0 +1,546 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	. "golog"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCacheMiss is returned in offline mode when no cached response exists
+var ErrCacheMiss = errors.New("goweb: offline cache miss")
+
+// CacheMode controls how HTTPCache participates in a request
+type CacheMode int
+
+const (
+	// CACHE_MODE_DEFAULT serves fresh entries and revalidates stale ones
+	CACHE_MODE_DEFAULT CacheMode = iota
+	// CACHE_MODE_BYPASS neither reads nor writes the cache
+	CACHE_MODE_BYPASS
+	// CACHE_MODE_OFFLINE serves any cached entry, fresh or not, and never touches the network
+	CACHE_MODE_OFFLINE
+)
+
+// statuses cacheable by default (RFC 7231 6.1)
+var _cacheableStatus = map[int]bool{200: true, 203: true, 204: true, 300: true, 301: true, 404: true, 405: true, 410: true, 414: true, 501: true}
+
+//
+// CacheEntry def
+//
+type CacheEntry struct {
+	Key          string
+	StatusCode   int
+	Header       http.Header
+	Body         []byte
+	RequestTime  time.Time
+	ResponseTime time.Time
+	// the request values of the headers named by Vary
+	VaryValues map[string]string
+}
+
+//
+// CacheEntry: The byte size accounted against cache limits
+//
+func (id *CacheEntry) Size() int {
+	size := len(id.Body) + len(id.Key)
+	for key, values := range id.Header {
+		size += len(key)
+		for _, value := range values {
+			size += len(value)
+		}
+	}
+	for key, value := range id.VaryValues {
+		size += len(key) + len(value)
+	}
+
+	return size
+}
+
+//
+// CacheEntry: The current age in seconds (RFC 7234 4.2.3)
+//
+func (id *CacheEntry) Age(now time.Time) time.Duration {
+	apparentAge := time.Duration(0)
+	if date, err := http.ParseTime(id.Header.Get("Date")); err == nil {
+		if delta := id.ResponseTime.Sub(date); delta > 0 {
+			apparentAge = delta
+		}
+	}
+
+	correctedAge := id.ResponseTime.Sub(id.RequestTime)
+	if ageValue, err := strconv.Atoi(id.Header.Get("Age")); err == nil {
+		correctedAge += time.Duration(ageValue) * time.Second
+	}
+
+	initialAge := apparentAge
+	if correctedAge > initialAge {
+		initialAge = correctedAge
+	}
+
+	return initialAge + now.Sub(id.ResponseTime)
+}
+
+//
+// CacheEntry: The freshness lifetime (RFC 7234 4.2.1), zero when the entry must revalidate
+//
+func (id *CacheEntry) FreshnessLifetime() time.Duration {
+	directives := ParseCacheControl(id.Header.Get("Cache-Control"))
+	if _, ok := directives["no-cache"]; ok {
+		return 0
+	}
+
+	if maxAge, ok := directives["max-age"]; ok {
+		if seconds, err := strconv.Atoi(maxAge); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		return 0
+	}
+
+	if expiresValue := id.Header.Get("Expires"); len(expiresValue) > 0 {
+		expires, err := http.ParseTime(expiresValue)
+		if err != nil {
+			// invalid dates (eg. "0") represent a time in the past
+			return 0
+		}
+		date, err := http.ParseTime(id.Header.Get("Date"))
+		if err != nil {
+			date = id.ResponseTime
+		}
+		return expires.Sub(date)
+	}
+
+	// heuristic freshness: 10% of the interval since Last-Modified
+	if lastModified, err := http.ParseTime(id.Header.Get("Last-Modified")); err == nil {
+		date, err := http.ParseTime(id.Header.Get("Date"))
+		if err != nil {
+			date = id.ResponseTime
+		}
+		if delta := date.Sub(lastModified); delta > 0 {
+			return delta / 10
+		}
+	}
+
+	return 0
+}
+
+//
+// CacheEntry: Is the entry fresh at the provided time
+//
+func (id *CacheEntry) IsFresh(now time.Time) bool {
+	return id.FreshnessLifetime() > id.Age(now)
+}
+
+//
+// CacheEntry: Does the request carry the header values the entry was stored for (RFC 7234 4.1)
+//
+func (id *CacheEntry) MatchesVary(header http.Header) bool {
+	for key, value := range id.VaryValues {
+		if strings.Join(header.Values(key), ",") != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+//
+// CacheEntry: Can the entry be revalidated with a conditional request
+//
+func (id *CacheEntry) HasValidators() bool {
+	return len(id.Header.Get("ETag")) > 0 || len(id.Header.Get("Last-Modified")) > 0
+}
+
+//
+// ParseCacheControl : Parse a Cache-Control header into lowercase directives
+//
+func ParseCacheControl(value string) (result map[string]string) {
+	result = make(map[string]string)
+	for _, directive := range strings.Split(value, ",") {
+		directive = strings.TrimSpace(directive)
+		if len(directive) == 0 {
+			continue
+		}
+		parts := strings.SplitN(directive, "=", 2)
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		if len(parts) == 2 {
+			result[key] = strings.Trim(strings.TrimSpace(parts[1]), "\"")
+		} else {
+			result[key] = ""
+		}
+	}
+
+	return result
+}
+
+//
+// CacheStore is the storage backend of an HTTPCache
+//
+type CacheStore interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry)
+	Delete(key string)
+}
+
+//
+// HTTPCache def
+//
+type HTTPCache struct {
+	Store CacheStore
+	Mode  CacheMode
+}
+
+//
+// NewHTTPCache constructor
+//
+func NewHTTPCache(store CacheStore) *HTTPCache {
+	return &HTTPCache{Store: store, Mode: CACHE_MODE_DEFAULT}
+}
+
+//
+// HTTP: Key the cache entry on the request method and URL
+//
+func (id *HTTP) cacheKey() string {
+	return id.Method + " " + id.URLString()
+}
+
+//
+// HTTP: Serve the request from the cache if possible, otherwise add validators
+//
+func (id *HTTP) cacheRequest() (cached bool, err error) {
+	id.cacheEntry = nil
+	id.requestTime = time.Now()
+	if id.Cache == nil || id.Cache.Store == nil || id.Cache.Mode == CACHE_MODE_BYPASS {
+		return
+	}
+
+	if id.Method != HTTP_GET && id.Method != HTTP_HEAD {
+		return
+	}
+
+	// a variant stored for other request headers is a miss
+	entry, ok := id.Cache.Store.Get(id.cacheKey())
+	if !ok || !entry.MatchesVary(id.req.Header) {
+		if id.Cache.Mode == CACHE_MODE_OFFLINE {
+			err = ErrCacheMiss
+		}
+		return
+	}
+
+	// the request may ask for revalidation or cap the age it accepts (RFC 7234 5.2.1)
+	fresh := entry.IsFresh(time.Now())
+	directives := ParseCacheControl(id.req.Header.Get("Cache-Control"))
+	if _, ok := directives["no-cache"]; ok || strings.EqualFold(id.req.Header.Get("Pragma"), "no-cache") {
+		fresh = false
+	}
+	if maxAge, ok := directives["max-age"]; ok {
+		if seconds, parseErr := strconv.Atoi(maxAge); parseErr != nil || entry.Age(time.Now()) > time.Duration(seconds)*time.Second {
+			fresh = false
+		}
+	}
+
+	if id.Cache.Mode == CACHE_MODE_OFFLINE || fresh {
+		LogDebug("Cache hit: " + entry.Key)
+		id.serveCacheEntry(entry)
+		cached = true
+		return
+	}
+
+	if entry.HasValidators() {
+		LogDebug("Cache revalidate: " + entry.Key)
+		if etag := entry.Header.Get("ETag"); len(etag) > 0 {
+			id.setRequestHeader("If-None-Match", etag)
+		}
+		if lastModified := entry.Header.Get("Last-Modified"); len(lastModified) > 0 {
+			id.setRequestHeader("If-Modified-Since", lastModified)
+		}
+		id.cacheEntry = entry
+	}
+
+	return
+}
+
+//
+// HTTP: Store a cacheable response or merge a 304 into the revalidated entry
+//
+func (id *HTTP) cacheResponse() {
+	if id.Cache == nil || id.Cache.Store == nil || id.Cache.Mode == CACHE_MODE_BYPASS || id.resp == nil {
+		return
+	}
+
+	if id.Method != HTTP_GET && id.Method != HTTP_HEAD {
+		// unsafe methods invalidate the stored response (RFC 7234 4.4)
+		id.Cache.Store.Delete(HTTP_GET + " " + id.URLString())
+		return
+	}
+
+	requestTime := id.requestTime
+	if id.resp.StatusCode == http.StatusNotModified && id.cacheEntry != nil {
+		// stores hold the previous entry, so update a copy
+		entry := *id.cacheEntry
+		entry.Header = id.cacheEntry.Header.Clone()
+		for key, values := range id.resp.Header {
+			entry.Header[key] = values
+		}
+		entry.RequestTime = requestTime
+		entry.ResponseTime = time.Now()
+		id.Cache.Store.Set(entry.Key, &entry)
+		id.serveCacheEntry(&entry)
+		return
+	}
+
+	if !_cacheableStatus[id.resp.StatusCode] {
+		return
+	}
+
+	directives := ParseCacheControl(id.resp.Header.Get("Cache-Control"))
+	if _, ok := directives["no-store"]; ok {
+		return
+	}
+
+	varyValues := map[string]string{}
+	for _, vary := range id.resp.Header.Values("Vary") {
+		for _, key := range strings.Split(vary, ",") {
+			key = http.CanonicalHeaderKey(strings.TrimSpace(key))
+			if key == "*" {
+				return
+			}
+			if len(key) > 0 {
+				varyValues[key] = strings.Join(id.req.Header.Values(key), ",")
+			}
+		}
+	}
+
+	entry := &CacheEntry{
+		Key:          id.cacheKey(),
+		StatusCode:   id.resp.StatusCode,
+		Header:       id.resp.Header.Clone(),
+		Body:         append([]byte(nil), id.RawContents...),
+		RequestTime:  requestTime,
+		ResponseTime: time.Now(),
+		VaryValues:   varyValues,
+	}
+
+	if entry.FreshnessLifetime() > 0 || entry.HasValidators() {
+		id.Cache.Store.Set(entry.Key, entry)
+	}
+}
+
+//
+// HTTP: Substitute the cache entry for the network response
+//
+func (id *HTTP) serveCacheEntry(entry *CacheEntry) {
+	// stores share their entries between requests, callers get their own copies
+	body := append([]byte(nil), entry.Body...)
+	id.resp = &http.Response{
+		Status:     strconv.Itoa(entry.StatusCode) + " " + http.StatusText(entry.StatusCode),
+		StatusCode: entry.StatusCode,
+		Header:     entry.Header.Clone(),
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+		Request:    id.req,
+	}
+
+	if id.Method == HTTP_HEAD {
+		id.RawContents = nil
+	} else {
+		id.RawContents = body
+	}
+}
+
+//
+// MemoryCache is an in-memory LRU CacheStore bounded by MaxBytes
+//
+type MemoryCache struct {
+	MaxBytes int
+	size     int
+	entries  map[string]*list.Element
+	lru      *list.List
+	lock     sync.Mutex
+}
+
+//
+// NewMemoryCache constructor, maxBytes <= 0 is unbounded
+//
+func NewMemoryCache(maxBytes int) *MemoryCache {
+	return &MemoryCache{MaxBytes: maxBytes, entries: make(map[string]*list.Element), lru: list.New()}
+}
+
+func (id *MemoryCache) Get(key string) (*CacheEntry, bool) {
+	id.lock.Lock()
+	defer id.lock.Unlock()
+
+	element, ok := id.entries[key]
+	if !ok {
+		return nil, false
+	}
+	id.lru.MoveToFront(element)
+
+	return element.Value.(*CacheEntry), true
+}
+
+func (id *MemoryCache) Set(key string, entry *CacheEntry) {
+	id.lock.Lock()
+	defer id.lock.Unlock()
+
+	id.remove(key)
+
+	size := entry.Size()
+	if id.MaxBytes > 0 && size > id.MaxBytes {
+		return
+	}
+
+	id.entries[key] = id.lru.PushFront(entry)
+	id.size += size
+
+	for id.MaxBytes > 0 && id.size > id.MaxBytes {
+		oldest := id.lru.Back()
+		id.remove(oldest.Value.(*CacheEntry).Key)
+	}
+}
+
+func (id *MemoryCache) Delete(key string) {
+	id.lock.Lock()
+	defer id.lock.Unlock()
+
+	id.remove(key)
+}
+
+//
+// MemoryCache: remove an entry, the caller holds the lock
+//
+func (id *MemoryCache) remove(key string) {
+	if element, ok := id.entries[key]; ok {
+		id.size -= element.Value.(*CacheEntry).Size()
+		id.lru.Remove(element)
+		delete(id.entries, key)
+	}
+}
+
+//
+// DiskCache is a CacheStore of JSON files in Dir bounded by MaxBytes
+//
+type DiskCache struct {
+	Dir      string
+	MaxBytes int64
+	lock     sync.Mutex
+}
+
+//
+// NewDiskCache constructor, maxBytes <= 0 is unbounded
+//
+func NewDiskCache(dir string, maxBytes int64) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &DiskCache{Dir: dir, MaxBytes: maxBytes}, nil
+}
+
+//
+// DiskCache: file path for the key
+//
+func (id *DiskCache) path(key string) string {
+	digest := sha256.Sum256([]byte(key))
+	return filepath.Join(id.Dir, hex.EncodeToString(digest[:])+".json")
+}
+
+func (id *DiskCache) Get(key string) (*CacheEntry, bool) {
+	id.lock.Lock()
+	defer id.lock.Unlock()
+
+	path := id.path(key)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	entry := &CacheEntry{}
+	if err = json.Unmarshal(data, entry); err != nil || entry.Key != key {
+		return nil, false
+	}
+
+	// mtime tracks recency for eviction
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	return entry, true
+}
+
+func (id *DiskCache) Set(key string, entry *CacheEntry) {
+	id.lock.Lock()
+	defer id.lock.Unlock()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		LogError(err)
+		return
+	}
+
+	if id.MaxBytes > 0 && int64(len(data)) > id.MaxBytes {
+		os.Remove(id.path(key))
+		return
+	}
+
+	if err = ioutil.WriteFile(id.path(key), data, 0644); err != nil {
+		LogError(err)
+		return
+	}
+
+	id.evict()
+}
+
+func (id *DiskCache) Delete(key string) {
+	id.lock.Lock()
+	defer id.lock.Unlock()
+
+	os.Remove(id.path(key))
+}
+
+//
+// DiskCache: remove the least recently used files until under MaxBytes
+//
+func (id *DiskCache) evict() {
+	if id.MaxBytes <= 0 {
+		return
+	}
+
+	infos, err := ioutil.ReadDir(id.Dir)
+	if err != nil {
+		return
+	}
+
+	var total int64
+	files := []os.FileInfo{}
+	for _, info := range infos {
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".json") {
+			total += info.Size()
+			files = append(files, info)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+
+	for i := 0; total > id.MaxBytes && i < len(files); i++ {
+		if os.Remove(filepath.Join(id.Dir, files[i].Name())) == nil {
+			total -= files[i].Size()
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newCacheTestServer(hits *int) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fresh", func(w http.ResponseWriter, r *http.Request) {
+		*hits += 1
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprint(w, "fresh")
+	})
+	mux.HandleFunc("/etag", func(w http.ResponseWriter, r *http.Request) {
+		*hits += 1
+		w.Header().Set("ETag", "\"v1\"")
+		w.Header().Set("Cache-Control", "no-cache")
+		if r.Header.Get("If-None-Match") == "\"v1\"" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "etag")
+	})
+
+	mux.HandleFunc("/vary", func(w http.ResponseWriter, r *http.Request) {
+		*hits += 1
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		fmt.Fprint(w, r.Header.Get("Accept-Language"))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestCacheFresh(t *testing.T) {
+	hits := 0
+	server := newCacheTestServer(&hits)
+	defer server.Close()
+
+	x := NewHTTP()
+	x.Cache = NewHTTPCache(NewMemoryCache(1024))
+	x.Get(server.URL + "/fresh")
+	r := x.Get(server.URL + "/fresh")
+	if r != "fresh" || hits != 1 {
+		t.Errorf("Cache result [%s] hits %d vs expected %d", r, hits, 1)
+	}
+
+	x.Cache.Mode = CACHE_MODE_BYPASS
+	x.Get(server.URL + "/fresh")
+	if hits != 2 {
+		t.Errorf("Cache bypass hits %d vs expected %d", hits, 2)
+	}
+}
+
+func TestCacheRevalidate(t *testing.T) {
+	hits := 0
+	server := newCacheTestServer(&hits)
+	defer server.Close()
+
+	store, err := NewDiskCache(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x := NewHTTP()
+	x.Cache = NewHTTPCache(store)
+	x.Get(server.URL + "/etag")
+	r := x.Get(server.URL + "/etag")
+	if r != "etag" || x.Status() != 200 || hits != 2 {
+		t.Errorf("Revalidate result [%s] status %d hits %d", r, x.Status(), hits)
+	}
+}
+
+func TestCacheOffline(t *testing.T) {
+	x := NewHTTP()
+	x.Cache = NewHTTPCache(NewMemoryCache(0))
+	x.Cache.Mode = CACHE_MODE_OFFLINE
+	x.Get("http://127.0.0.1:1/missing")
+	if !errors.Is(x.LastError(), ErrCacheMiss) {
+		t.Errorf("Offline miss not reported %v", x.LastError())
+	}
+}
+
+func TestCacheVary(t *testing.T) {
+	hits := 0
+	server := newCacheTestServer(&hits)
+	defer server.Close()
+
+	x := NewHTTP()
+	x.Cache = NewHTTPCache(NewMemoryCache(1024))
+	x.Header = http.Header{}
+	for i, language := range []string{"en", "en", "fr"} {
+		x.Header.Set("Accept-Language", language)
+		if r := x.Get(server.URL + "/vary"); r != language {
+			t.Errorf("request %d expected the %s variant, got [%s]", i, language, r)
+		}
+	}
+	if hits != 2 {
+		t.Errorf("Cache vary hits %d vs expected %d", hits, 2)
+	}
+}
+
+func TestCacheRequestNoCache(t *testing.T) {
+	hits := 0
+	server := newCacheTestServer(&hits)
+	defer server.Close()
+
+	x := NewHTTP()
+	x.Cache = NewHTTPCache(NewMemoryCache(1024))
+	x.Get(server.URL + "/fresh")
+
+	// the caller forces revalidation of a fresh entry
+	for i, directive := range []string{"no-cache", "max-age=0"} {
+		x.Header = http.Header{"Cache-Control": {directive}}
+		if r := x.Get(server.URL + "/fresh"); r != "fresh" || hits != i+2 {
+			t.Errorf("%s result [%s] hits %d vs expected %d", directive, r, hits, i+2)
+		}
+	}
+
+	x.Header = http.Header{"Cache-Control": {"max-age=30"}}
+	if x.Get(server.URL + "/fresh"); hits != 3 {
+		t.Errorf("max-age=30 hits %d vs expected %d", hits, 3)
+	}
+}
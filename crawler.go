@@ -0,0 +1,365 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"errors"
+	. "golog"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrCrawlerRunning is returned when Start is called on a running Crawler
+var ErrCrawlerRunning = errors.New("goweb: crawler already running")
+
+//
+// CrawlPage def
+//
+type CrawlPage struct {
+	URL         string
+	FinalURL    string
+	Referrer    string
+	Depth       int
+	Status      int
+	ContentType string
+	Contents    string
+	DOM         *DOM
+	Links       []string
+	Err         error
+}
+
+//
+// frontier entry
+//
+type _crawlItem struct {
+	url      string
+	referrer string
+	depth    int
+}
+
+//
+// Crawler def
+// Workers fetch pages breadth first from the frontier, each with its own HTTP
+// from HTTPFactory since an HTTP is not safe for concurrent use.
+//
+type Crawler struct {
+	Workers     int
+	MaxDepth    int
+	MaxPages    int
+	Domains     []string
+	HTTPFactory func() *HTTP
+	OnPage      func(page *CrawlPage)
+
+	queue   []*_crawlItem
+	seen    map[string]bool
+	scope   []string
+	active  int
+	running bool
+	paused  bool
+	stopped bool
+	lock    sync.Mutex
+	cond    *sync.Cond
+	wg      sync.WaitGroup
+}
+
+//
+// NewCrawler constructor
+//
+func NewCrawler() *Crawler {
+	id := &Crawler{Workers: 4, MaxDepth: 2, HTTPFactory: NewHTTP}
+	id.cond = sync.NewCond(&id.lock)
+
+	return id
+}
+
+//
+// Start : Seed the frontier and start the workers, returns immediately
+//
+func (id *Crawler) Start(seeds ...string) error {
+	id.lock.Lock()
+	if id.running {
+		id.lock.Unlock()
+		return ErrCrawlerRunning
+	}
+
+	id.queue = nil
+	id.seen = make(map[string]bool)
+	id.active = 0
+	id.running = true
+	id.paused = false
+	id.stopped = false
+
+	// without explicit domains the crawl is scoped to the seed hosts
+	id.scope = append([]string(nil), id.Domains...)
+	scopeSeeds := len(id.scope) == 0
+	for _, seed := range seeds {
+		seedURL, err := url.Parse(seed)
+		if err != nil {
+			id.running = false
+			id.lock.Unlock()
+			return err
+		}
+		if len(seedURL.Scheme) == 0 {
+			seedURL, _ = url.Parse("http://" + seed)
+		}
+		if scopeSeeds {
+			id.scope = append(id.scope, seedURL.Hostname())
+		}
+		id.enqueue(seedURL, "", 0)
+	}
+	id.lock.Unlock()
+
+	workers := id.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	id.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go id.worker()
+	}
+
+	return nil
+}
+
+//
+// Wait : Block until the frontier is exhausted or the crawler is stopped
+//
+func (id *Crawler) Wait() {
+	id.wg.Wait()
+
+	id.lock.Lock()
+	id.running = false
+	id.lock.Unlock()
+}
+
+//
+// Run : Start and Wait
+//
+func (id *Crawler) Run(seeds ...string) error {
+	if err := id.Start(seeds...); err != nil {
+		return err
+	}
+	id.Wait()
+
+	return nil
+}
+
+//
+// Pause : Workers finish their current page and then idle
+//
+func (id *Crawler) Pause() {
+	id.lock.Lock()
+	id.paused = true
+	id.lock.Unlock()
+}
+
+//
+// Resume : Resume a paused crawl
+//
+func (id *Crawler) Resume() {
+	id.lock.Lock()
+	id.paused = false
+	id.lock.Unlock()
+	id.cond.Broadcast()
+}
+
+//
+// Stop : Graceful shutdown, in flight pages complete and the frontier is dropped
+//
+func (id *Crawler) Stop() {
+	id.lock.Lock()
+	id.stopped = true
+	id.queue = nil
+	id.lock.Unlock()
+	id.cond.Broadcast()
+}
+
+//
+// Visited : The count of normalized URLs seen so far
+//
+func (id *Crawler) Visited() int {
+	id.lock.Lock()
+	defer id.lock.Unlock()
+
+	return len(id.seen)
+}
+
+//
+// Crawler: Add the URL to the frontier if in scope and unseen, the caller holds the lock
+//
+func (id *Crawler) enqueue(u *url.URL, referrer string, depth int) {
+	if id.stopped || (id.MaxDepth >= 0 && depth > id.MaxDepth) {
+		return
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+
+	if !id.inScope(u.Hostname()) {
+		return
+	}
+
+	key := NormalizeURL(u)
+	if id.seen[key] {
+		return
+	}
+	if id.MaxPages > 0 && len(id.seen) >= id.MaxPages {
+		return
+	}
+
+	id.seen[key] = true
+	id.queue = append(id.queue, &_crawlItem{url: key, referrer: referrer, depth: depth})
+}
+
+//
+// Crawler: Is the host one of the domains or a subdomain of one
+//
+func (id *Crawler) inScope(host string) bool {
+	host = strings.ToLower(host)
+	for _, domain := range id.scope {
+		domain = strings.ToLower(domain)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//
+// Crawler: Pull from the frontier until it is exhausted or the crawl is stopped
+//
+func (id *Crawler) worker() {
+	defer id.wg.Done()
+
+	client := id.HTTPFactory()
+
+	for {
+		id.lock.Lock()
+		for !id.stopped && (id.paused || len(id.queue) == 0) && (len(id.queue) > 0 || id.active > 0) {
+			id.cond.Wait()
+		}
+		if id.stopped || (len(id.queue) == 0 && id.active == 0) {
+			id.lock.Unlock()
+			id.cond.Broadcast()
+			return
+		}
+
+		item := id.queue[0]
+		id.queue = id.queue[1:]
+		id.active += 1
+		id.lock.Unlock()
+
+		page := id.fetch(client, item)
+
+		if id.OnPage != nil {
+			id.OnPage(page)
+		}
+
+		id.lock.Lock()
+		if len(page.FinalURL) > 0 {
+			// the redirect target counts as seen too
+			if finalURL, err := url.Parse(page.FinalURL); err == nil {
+				id.seen[NormalizeURL(finalURL)] = true
+			}
+		}
+		for _, link := range page.Links {
+			if linkURL, err := url.Parse(link); err == nil {
+				id.enqueue(linkURL, page.URL, item.depth+1)
+			}
+		}
+		id.active -= 1
+		id.lock.Unlock()
+		id.cond.Broadcast()
+	}
+}
+
+//
+// Crawler: Fetch the page and extract its links
+//
+func (id *Crawler) fetch(client *HTTP, item *_crawlItem) (page *CrawlPage) {
+	LogDebugf("Crawl depth %d: %s", item.depth, item.url)
+
+	page = &CrawlPage{URL: item.url, Referrer: item.referrer, Depth: item.depth}
+
+	// a fresh URL each fetch, relative resolution against the last page is unwanted
+	client.URL = nil
+	page.Contents = client.Get(item.url)
+	page.Err = client.LastError()
+	if page.Err != nil {
+		return page
+	}
+
+	page.FinalURL = client.URLString()
+	page.Status = client.Status()
+	page.ContentType = client.ContentType()
+
+	if client.isHTML() {
+		page.DOM = NewDOM()
+		page.DOM.SetContents(page.Contents)
+		page.Links = ExtractLinks(page.DOM, client.URL)
+	}
+
+	return page
+}
+
+//
+// ExtractLinks : The absolute, fragment free hyperlinks of the DOM resolved against pageURL
+//
+func ExtractLinks(d *DOM, pageURL *url.URL) (result []string) {
+	baseURL := d._baseURL(pageURL)
+
+	for _, tag := range []string{"a", "area"} {
+		for _, node := range d.Find(tag, nil) {
+			href := strings.TrimSpace(node.Attr("href"))
+			if len(href) == 0 || strings.HasPrefix(href, "#") {
+				continue
+			}
+			linkURL, err := baseURL.Parse(href)
+			if err != nil {
+				continue
+			}
+			linkURL.Fragment = ""
+			result = append(result, linkURL.String())
+		}
+	}
+
+	return result
+}
+
+//
+// NormalizeURL : Canonical form used for de-duplication
+// Lowercase scheme and host, no default port, no fragment, a non empty path and sorted query.
+//
+func NormalizeURL(u *url.URL) string {
+	normal := *u
+	normal.Scheme = strings.ToLower(normal.Scheme)
+	normal.Host = strings.ToLower(normal.Host)
+	normal.Fragment = ""
+	normal.RawFragment = ""
+
+	if port := normal.Port(); (normal.Scheme == "http" && port == "80") || (normal.Scheme == "https" && port == "443") {
+		// keeps the brackets of an IPv6 literal
+		normal.Host = strings.TrimSuffix(normal.Host, ":"+port)
+	}
+
+	if len(normal.Path) == 0 {
+		normal.Path = "/"
+	}
+	// collapse any dot segments
+	normal = *normal.ResolveReference(&url.URL{Path: normal.Path, RawQuery: normal.RawQuery})
+
+	if len(normal.RawQuery) > 0 {
+		params := strings.Split(normal.RawQuery, "&")
+		sort.Strings(params)
+		normal.RawQuery = strings.Join(params, "&")
+	}
+
+	return normal.String()
+}
@@ -0,0 +1,80 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func newCrawlTestServer() *httptest.Server {
+	pages := map[string]string{
+		"/":         `<a href="/a">a</a><a href="b#top">b</a><a href="http://external.example.com/">x</a>`,
+		"/a":        `<a href="/">home</a><a href="/a/deep">deep</a>`,
+		"/b":        `<a href="./a?y=2&x=1">a</a><a href="./a?x=1&y=2">a</a>`,
+		"/a/deep":   `<a href="/a/deeper">deeper</a>`,
+		"/a/deeper": `<p>end</p>`,
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contents, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><body>%s</body></html>", contents)
+	}))
+}
+
+func TestCrawler(t *testing.T) {
+	server := newCrawlTestServer()
+	defer server.Close()
+
+	var lock sync.Mutex
+	visited := map[string]int{}
+
+	c := NewCrawler()
+	c.MaxDepth = 2
+	c.OnPage = func(page *CrawlPage) {
+		lock.Lock()
+		defer lock.Unlock()
+		u, _ := url.Parse(page.URL)
+		visited[u.RequestURI()] += 1
+	}
+
+	if err := c.Run(server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"/", "/a", "/b", "/a/deep", "/a?x=1&y=2"}
+	for _, path := range expected {
+		if visited[path] != 1 {
+			t.Errorf("Crawl of %s visited %d times [%v]", path, visited[path], visited)
+		}
+	}
+	if len(visited) != len(expected) {
+		t.Errorf("Crawl visited %d pages vs expected %d [%v]", len(visited), len(expected), visited)
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	u, _ := url.Parse("HTTP://Example.COM:80/a/../b?z=1&a=2#frag")
+	if r := NormalizeURL(u); r != "http://example.com/b?a=2&z=1" {
+		t.Errorf("NormalizeURL [%s]", r)
+	}
+
+	// IPv6 literals keep their brackets
+	for raw, expected := range map[string]string{"http://[::1]:80/": "http://[::1]/", "http://[FE80::1]:8080/a": "http://[fe80::1]:8080/a"} {
+		u, _ = url.Parse(raw)
+		if r := NormalizeURL(u); r != expected {
+			t.Errorf("NormalizeURL %s [%s]", raw, r)
+		}
+	}
+}
@@ -9,14 +9,35 @@ import (
 	"golang.org/x/net/html"
 	. "golog"
 	"strings"
-	"sync"
 )
 
 // DOMNodeAttributes map of strings keyed by strings
 type DOMNodeAttributes map[string]string
 
+// DOM_TEXT_TAG is the tag of text nodes, which only appear in ChildNodes
+const DOM_TEXT_TAG = "#text"
+
+const (
+	DOM_COMMENT_TAG  = "comment"
+	DOM_DOCTYPE_TAG  = "doctype"
+	DOM_DOCUMENT_TAG = "document"
+	DOM_ERROR_TAG    = "error"
+)
+
+var (
+	// elements whose content is kept verbatim as a single text node, never parsed into nodes
+	_domRawTextTags = map[string]bool{"script": true, "style": true, "template": true, "noscript": true}
+	// elements whose content is never part of the reader text
+	_domNonReaderTags = map[string]bool{"script": true, "style": true, "template": true, "noscript": true}
+	// the parser node kinds that are not elements, they appear in ChildNodes but not Children
+	_domNonElementTags = map[string]bool{DOM_TEXT_TAG: true, DOM_COMMENT_TAG: true, DOM_DOCTYPE_TAG: true, DOM_DOCUMENT_TAG: true, DOM_ERROR_TAG: true}
+)
+
 //
 // DOMNode def
+// ChildNodes holds the text, comment and element children in document order,
+// Children the elements only and TextFragments the trimmed non empty direct text.
+// Data is the content of text and comment nodes and the name of doctype nodes.
 //
 type DOMNode struct {
 	Index         int
@@ -25,13 +46,38 @@ type DOMNode struct {
 	TextFragments []string
 	Parent        *DOMNode
 	Children      []*DOMNode
+	ChildNodes    []*DOMNode
+	Data          string
+
+	dom *DOM
 }
 
 //
 // NewDOMNode constructor
 //
 func NewDOMNode(index int, parent *DOMNode, tag string, attributes DOMNodeAttributes) *DOMNode {
-	return &DOMNode{Index: index, Parent: parent, Children: []*DOMNode{}, Tag: strings.ToLower(tag), Attributes: attributes}
+	return &DOMNode{Index: index, Parent: parent, Children: []*DOMNode{}, ChildNodes: []*DOMNode{}, Tag: strings.ToLower(tag), Attributes: attributes}
+}
+
+//
+// NewDOMTextNode constructor
+//
+func NewDOMTextNode(text string) *DOMNode {
+	return &DOMNode{Tag: DOM_TEXT_TAG, Data: text, Children: []*DOMNode{}, ChildNodes: []*DOMNode{}}
+}
+
+//
+// IsText Node: Is this a text node?
+//
+func (id *DOMNode) IsText() bool {
+	return id.Tag == DOM_TEXT_TAG
+}
+
+//
+// IsElement Node: Is this an element, rather than a text, comment, doctype or document node?
+//
+func (id *DOMNode) IsElement() bool {
+	return !_domNonElementTags[id.Tag]
 }
 
 //
@@ -73,45 +119,107 @@ func (id *DOMNode) Text() (result string) {
 }
 
 //
-// ReaderText recombines the node text fragments into the human reader visibile text
+// RawText : The verbatim content of the node, the source of script, style, template and noscript
+//
+func (id *DOMNode) RawText() (result string) {
+	for _, node := range id.ChildNodes {
+		if node.IsText() {
+			result += node.Data
+		}
+	}
+
+	return result
+}
+
+//
+// ReaderText recombines the node text, and that of its descendants, in document order
+// Script, style, template and noscript content is not reader visible.
 //
 func (id *DOMNode) ReaderText() (result string) {
-	fragCount := len(id.TextFragments)
-	childCount := len(id.Children)
+	if _domNonReaderTags[id.Tag] {
+		return ""
+	}
 
-	// recursively recombine any child node text fragments
-	if childCount == 0 {
-		// there are no children, defer to Text()
-		result = id.Text()
-	} else {
-		i := 0
+	// Text() already covers the common childless case
+	if len(id.Children) == 0 {
+		return id.Text()
+	}
 
-		// there may not be a leading fragment, be we assume there is
-		if i < fragCount {
-			result += id.TextFragments[i] + " "
-			i += 1
-		}
+	w := &_textWriter{flat: true}
+	w.node(id)
 
-		// interleave child text and fragments until there are no more
-		for _, child := range id.Children {
-			childText := child.ReaderText()
-			if len(childText) > 0 {
-				result += childText + " "
-				if fragCount > 2 && i < fragCount {
-					result += id.TextFragments[i] + " "
-					i += 1
-				}
-			}
-		}
-		result = strings.TrimSpace(result)
+	return w.String()
+}
 
-		// any trailing fragments, this should be at most count 1
-		if i < fragCount {
-			result += " " + strings.Join(id.TextFragments[i:], " ")
-		}
+//
+// NextSibling : The following text or element node of the parent, nil if last
+//
+func (id *DOMNode) NextSibling() *DOMNode {
+	if id.Parent == nil {
+		return nil
 	}
 
-	return
+	position := id.Parent._childPosition(id)
+	if position < 0 || position+1 >= len(id.Parent.ChildNodes) {
+		return nil
+	}
+
+	return id.Parent.ChildNodes[position+1]
+}
+
+//
+// PrevSibling : The preceding text or element node of the parent, nil if first
+//
+func (id *DOMNode) PrevSibling() *DOMNode {
+	if id.Parent == nil {
+		return nil
+	}
+
+	position := id.Parent._childPosition(id)
+	if position < 1 {
+		return nil
+	}
+
+	return id.Parent.ChildNodes[position-1]
+}
+
+//
+// NextElementSibling : The following element of the parent, nil if last
+//
+func (id *DOMNode) NextElementSibling() *DOMNode {
+	node := id.NextSibling()
+	for node != nil && !node.IsElement() {
+		node = node.NextSibling()
+	}
+
+	return node
+}
+
+//
+// PrevElementSibling : The preceding element of the parent, nil if first
+//
+func (id *DOMNode) PrevElementSibling() *DOMNode {
+	node := id.PrevSibling()
+	for node != nil && !node.IsElement() {
+		node = node.PrevSibling()
+	}
+
+	return node
+}
+
+//
+// Node: Append a parsed child in document order
+//
+func (id *DOMNode) _appendChildNode(node *DOMNode) {
+	node.Parent = id
+	id.ChildNodes = append(id.ChildNodes, node)
+	if node.IsElement() {
+		id.Children = append(id.Children, node)
+	} else if node.IsText() {
+		if text := strings.TrimSpace(node.Data); len(text) > 0 {
+			id.TextFragments = append(id.TextFragments, text)
+		}
+	}
 }
 
 //
@@ -158,14 +266,82 @@ func (id *DOM) SetContents(htmlString string) {
 
 	id.contents = htmlString
 
+	// noscript content is raw text, as in a browser with scripting
 	doc, err := html.Parse(strings.NewReader(htmlString))
 	if err == nil {
-		id._parseHTMLNode(nil, doc, false)
+		id._parseHTMLNode(nil, doc)
+		id._restoreTemplates()
 	} else {
 		LogError(err)
 	}
 }
 
+//
+// DOM: Put back the template content as written, the parser only hands out its nodes
+// Templates pair with the source in order, a mismatch keeps the re-serialized content.
+//
+func (id *DOM) _restoreTemplates() {
+	templates := id.nodes["template"]
+	if len(templates) == 0 {
+		return
+	}
+
+	sources := _templateSources(id.contents)
+	if len(sources) != len(templates) {
+		LogDebug("DOM template sources unmatched, keeping the parsed content")
+		return
+	}
+
+	for i, node := range templates {
+		if node.RawText() != sources[i] {
+			node.SetText(sources[i])
+		}
+	}
+}
+
+//
+// DOM: The content of each outermost template element, sliced from the source
+//
+func _templateSources(contents string) (result []string) {
+	z := html.NewTokenizer(strings.NewReader(contents))
+	offset, start, depth := 0, 0, 0
+	for {
+		tokenType := z.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		tokenStart := offset
+		offset += len(z.Raw())
+
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken && tokenType != html.EndTagToken {
+			continue
+		}
+		if name, _ := z.TagName(); string(name) != "template" {
+			continue
+		}
+
+		// the parser ignores the self closing flag on templates
+		if tokenType != html.EndTagToken {
+			if depth == 0 {
+				start = offset
+			}
+			depth++
+		} else if depth > 0 {
+			depth--
+			if depth == 0 {
+				result = append(result, contents[start:tokenStart])
+			}
+		}
+	}
+
+	// an unclosed template runs to the end of the document
+	if depth > 0 {
+		result = append(result, contents[start:])
+	}
+
+	return result
+}
+
 //
 // Contents : The raw html contents.
 //
@@ -185,8 +361,7 @@ func (id *DOM) ContentLength() int {
 //
 func (id *DOM) RootNode() (result *DOMNode) {
 	if id.rootNode == nil {
-		// we're looking for the tidy-ed HTML node at index 1
-		// there's the childless DOCUMENT node at index 0
+		// we're looking for the tidy-ed HTML node below the DOCUMENT node
 		for i := 0; i < len(id.document); i++ {
 			if id.document[i].Tag == "html" {
 				id.rootNode = id.document[i]
@@ -219,89 +394,93 @@ func (id *DOM) _parseHTMLNodeAttributes(node *html.Node) (attrs DOMNodeAttribute
 	return attrs
 }
 
-//
-// DOM: Parse the Token attributes into a map.
-//
-func (id *DOM) _parseHTMLFragment(parent *DOMNode, current *html.Node, contents string) {
-	nodes, err := html.ParseFragment(strings.NewReader(contents), current)
-	if err == nil {
-		for _, node := range nodes {
-			id._parseHTMLNode(parent, node, true)
-		}
-	}
-}
-
 //
 // DOM: Walk the DOM and parse the HTML tokens into Nodes.
 //
-var (
-	parseSkipTags    map[string]int
-	fragmentSkipTags map[string]int
-	once             sync.Once
-)
-
-func (id *DOM) _parseHTMLNode(parent *DOMNode, current *html.Node, fragment bool) {
-	// these are reusable and constant, good singleton candidates
-	once.Do(func() {
-		parseSkipTags = map[string]int{"script": 1, "style": 1, "body": 1}
-		fragmentSkipTags = map[string]int{"html": 1, "head": 1, "body": 1}
-	})
-
+func (id *DOM) _parseHTMLNode(parent *DOMNode, current *html.Node) {
 	switch current.Type {
 	case html.ElementNode:
-		if !fragment || (fragment && fragmentSkipTags[current.Data] == 0) {
-			id.nodeCount += 1
-			domNode := NewDOMNode(id.nodeCount, parent, current.Data, id._parseHTMLNodeAttributes(current))
-			// set the children and swap
-			if parent != nil {
-				parent.Children = append(parent.Children, domNode)
-			}
-			parent = domNode
-			id.document = append(id.document, domNode)
-			nodeArr := id.nodes[domNode.Tag]
-			if nodeArr != nil {
-				id.nodes[domNode.Tag] = append(nodeArr, domNode)
-			} else {
-				id.nodes[domNode.Tag] = []*DOMNode{domNode}
+		domNode := id._newNode(parent, current.Data, id._parseHTMLNodeAttributes(current))
+		// swap, the children attach to the new node
+		parent = domNode
+
+		if _domRawTextTags[domNode.Tag] {
+			if raw := _rawHTMLContent(current); len(raw) > 0 {
+				textNode := NewDOMTextNode(raw)
+				textNode.dom = id
+				domNode._appendChildNode(textNode)
 			}
+			return
 		}
 	case html.TextNode:
-		text := strings.TrimSpace(current.Data)
-		if strings.Index(text, "<") != -1 && (current.Parent == nil || parseSkipTags[current.Parent.Data] == 0) {
-			id._parseHTMLFragment(parent, current.Parent, text)
-		} else {
-			// we need to handle structures like (eg. <div>foo<strong>baz</strong>bar</div>)
-			// Assumption: if the current node already has text, it belongs to the parent
-			currentNode := id.document[len(id.document)-1]
-			if currentNode != nil && len(currentNode.TextFragments) != 0 {
-				currentNode = currentNode.Parent
-			}
-			if currentNode != nil {
-				currentNode.TextFragments = append(currentNode.TextFragments, text)
-			}
+		// text nodes keep their place among the children (eg. <div>foo<strong>baz</strong>bar</div>),
+		// the parser has already decoded any escaped markup into plain text
+		if parent != nil {
+			textNode := NewDOMTextNode(current.Data)
+			textNode.dom = id
+			parent._appendChildNode(textNode)
 		}
 	case html.CommentNode:
-		id.nodeCount += 1
-		domNode := NewDOMNode(id.nodeCount, parent, "comment", id._parseHTMLNodeAttributes(current))
-		id.document = append(id.document, domNode)
+		id._newNode(parent, DOM_COMMENT_TAG, id._parseHTMLNodeAttributes(current)).Data = current.Data
 	case html.ErrorNode:
-		id.nodeCount += 1
-		domNode := NewDOMNode(id.nodeCount, parent, "error", id._parseHTMLNodeAttributes(current))
-		id.document = append(id.document, domNode)
+		id._newNode(parent, DOM_ERROR_TAG, id._parseHTMLNodeAttributes(current)).Data = current.Data
 	case html.DocumentNode:
-		id.nodeCount += 1
-		domNode := NewDOMNode(id.nodeCount, parent, "document", id._parseHTMLNodeAttributes(current))
-		id.document = append(id.document, domNode)
+		// the document node tops the tree, holding the doctype, comments and html
+		parent = id._newNode(parent, DOM_DOCUMENT_TAG, id._parseHTMLNodeAttributes(current))
 	case html.DoctypeNode:
-		id.nodeCount += 1
-		domNode := NewDOMNode(id.nodeCount, parent, "doctype", id._parseHTMLNodeAttributes(current))
-		id.document = append(id.document, domNode)
+		// the public and system identifiers are kept as attributes
+		id._newNode(parent, DOM_DOCTYPE_TAG, id._parseHTMLNodeAttributes(current)).Data = current.Data
 	}
 
 	// recurse for all child nodes
 	for child := current.FirstChild; child != nil; child = child.NextSibling {
-		id._parseHTMLNode(parent, child, fragment)
+		id._parseHTMLNode(parent, child)
+	}
+}
+
+//
+// DOM: The content of a raw text element as written, template content is re-serialized until restored
+//
+func _rawHTMLContent(current *html.Node) string {
+	var out strings.Builder
+	for child := current.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.TextNode {
+			out.WriteString(child.Data)
+		} else {
+			html.Render(&out, child)
+		}
 	}
+
+	return out.String()
+}
+
+//
+// DOM: Create the next node in document order, attached to parent and indexed by tag
+//
+func (id *DOM) _newNode(parent *DOMNode, tag string, attributes DOMNodeAttributes) *DOMNode {
+	id.nodeCount += 1
+	domNode := NewDOMNode(id.nodeCount, parent, tag, attributes)
+	domNode.dom = id
+	id.document = append(id.document, domNode)
+	id.nodes[domNode.Tag] = append(id.nodes[domNode.Tag], domNode)
+	if parent != nil {
+		parent._appendChildNode(domNode)
+	}
+
+	return domNode
+}
+
+//
+// Document : The document node, parent of the doctype, top level comments and the HTML root node
+//
+func (id *DOM) Document() *DOMNode {
+	for _, node := range id.document {
+		if node.Tag == DOM_DOCUMENT_TAG && node.Parent == nil {
+			return node
+		}
+	}
+
+	return nil
 }
 
 //
@@ -320,8 +499,7 @@ func (id *DOM) IsDescendantNode(parent *DOMNode, node *DOMNode) (result bool) {
 		result = true
 	} else {
 		// we would have matched above if parent and node were the root node
-		rootNode := id.RootNode()
-		for node != nil && parent.Index <= node.Index && node != rootNode {
+		for node != nil && parent.Index <= node.Index {
 			if node.Parent == parent {
 				result = true
 				break
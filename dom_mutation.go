@@ -0,0 +1,295 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ErrDOMHierarchy is returned when a mutation would misplace a node, such as inside itself
+var ErrDOMHierarchy = errors.New("goweb: invalid DOM hierarchy")
+
+//
+// AppendChild : Move or insert child as the last child of the node
+//
+func (id *DOMNode) AppendChild(child *DOMNode) error {
+	return id.InsertBefore(child, nil)
+}
+
+//
+// InsertBefore : Move or insert child before reference, a nil reference appends
+//
+func (id *DOMNode) InsertBefore(child *DOMNode, reference *DOMNode) error {
+	if child == nil || child == reference || id.IsText() {
+		return ErrDOMHierarchy
+	}
+	for ancestor := id; ancestor != nil; ancestor = ancestor.Parent {
+		if ancestor == child {
+			return ErrDOMHierarchy
+		}
+	}
+	if reference != nil && reference.Parent != id {
+		return ErrDOMHierarchy
+	}
+
+	if child.dom != nil {
+		child.dom._unindex(child)
+	}
+	child._detach()
+
+	position := len(id.ChildNodes)
+	if reference != nil {
+		position = id._childPosition(reference)
+	}
+	id.ChildNodes = append(id.ChildNodes, nil)
+	copy(id.ChildNodes[position+1:], id.ChildNodes[position:])
+	id.ChildNodes[position] = child
+	child.Parent = id
+	id._syncChildren()
+
+	if id.dom != nil {
+		id.dom._index(child)
+	} else {
+		child._adopt(nil)
+	}
+
+	return nil
+}
+
+//
+// Remove : Detach the node, and its subtree, from the document
+//
+func (id *DOMNode) Remove() {
+	if id.dom != nil {
+		id.dom._unindex(id)
+	}
+	id._detach()
+	id._adopt(nil)
+}
+
+//
+// ReplaceWith : Put node in place of this node, which is removed
+//
+func (id *DOMNode) ReplaceWith(node *DOMNode) error {
+	if id.Parent == nil {
+		return ErrDOMHierarchy
+	}
+	if node == id {
+		return nil
+	}
+
+	if err := id.Parent.InsertBefore(node, id); err != nil {
+		return err
+	}
+	id.Remove()
+
+	return nil
+}
+
+//
+// SetAttr : Set the attribute value
+//
+func (id *DOMNode) SetAttr(key string, value string) {
+	if id.Attributes == nil {
+		id.Attributes = make(DOMNodeAttributes)
+	}
+
+	id.Attributes[strings.ToLower(key)] = value
+}
+
+//
+// RemoveAttr : Delete the attribute
+//
+func (id *DOMNode) RemoveAttr(key string) {
+	delete(id.Attributes, strings.ToLower(key))
+}
+
+//
+// SetText : Replace the content of the node, children included, with text
+//
+func (id *DOMNode) SetText(text string) {
+	if id.IsText() {
+		id.Data = text
+		if id.Parent != nil {
+			id.Parent._syncChildren()
+		}
+		return
+	}
+
+	for _, child := range id.ChildNodes {
+		if id.dom != nil {
+			id.dom._unindex(child)
+		}
+		child.Parent = nil
+		child._adopt(nil)
+	}
+	textNode := NewDOMTextNode(text)
+	textNode.Parent = id
+	textNode.dom = id.dom
+	id.ChildNodes = []*DOMNode{textNode}
+	id._syncChildren()
+}
+
+//
+// Node: Unlink the node from its parent
+//
+func (id *DOMNode) _detach() {
+	if id.Parent != nil {
+		if position := id.Parent._childPosition(id); position > -1 {
+			id.Parent.ChildNodes = append(id.Parent.ChildNodes[:position], id.Parent.ChildNodes[position+1:]...)
+			id.Parent._syncChildren()
+		}
+		id.Parent = nil
+	}
+}
+
+//
+// Node: The position of child among the child nodes, -1 if absent
+//
+func (id *DOMNode) _childPosition(child *DOMNode) int {
+	for i, node := range id.ChildNodes {
+		if node == child {
+			return i
+		}
+	}
+
+	return -1
+}
+
+//
+// Node: Derive the element children and text fragments from the child nodes
+//
+func (id *DOMNode) _syncChildren() {
+	id.Children = []*DOMNode{}
+	id.TextFragments = nil
+	for _, node := range id.ChildNodes {
+		if node.IsElement() {
+			id.Children = append(id.Children, node)
+		} else if text := strings.TrimSpace(node.Data); node.IsText() && len(text) > 0 {
+			id.TextFragments = append(id.TextFragments, text)
+		}
+	}
+}
+
+//
+// Node: Bind the subtree to dom
+//
+func (id *DOMNode) _adopt(dom *DOM) {
+	id.dom = dom
+	for _, child := range id.ChildNodes {
+		child._adopt(dom)
+	}
+}
+
+//
+// Node: The subtree in document order, text nodes excluded as they are not indexed
+//
+func (id *DOMNode) _indexedNodes(result []*DOMNode) []*DOMNode {
+	if id.IsText() {
+		return result
+	}
+
+	result = append(result, id)
+	for _, child := range id.ChildNodes {
+		result = child._indexedNodes(result)
+	}
+
+	return result
+}
+
+//
+// Node: The last indexed node of the subtree in document order
+//
+func (id *DOMNode) _lastIndexedNode() *DOMNode {
+	last := id
+	for descended := true; descended; {
+		descended = false
+		for i := len(last.ChildNodes) - 1; i >= 0; i-- {
+			if !last.ChildNodes[i].IsText() {
+				last = last.ChildNodes[i]
+				descended = true
+				break
+			}
+		}
+	}
+
+	return last
+}
+
+//
+// DOM: Index the subtree of node, just linked below an indexed parent
+// Only the touched subtree is tagged, the nodes after it are renumbered.
+//
+func (id *DOM) _index(node *DOMNode) {
+	node._adopt(id)
+	id.rootNode = nil
+
+	subtree := node._indexedNodes(nil)
+	if len(subtree) == 0 {
+		return
+	}
+
+	// the subtree follows its previous indexed sibling, or else its parent
+	position := node.Parent.Index
+	for i := node.Parent._childPosition(node) - 1; i >= 0; i-- {
+		if sibling := node.Parent.ChildNodes[i]; !sibling.IsText() {
+			position = sibling._lastIndexedNode().Index
+			break
+		}
+	}
+
+	id.document = append(id.document[:position], append(subtree, id.document[position:]...)...)
+	id._renumber(position)
+
+	for _, indexed := range subtree {
+		tagged := id.nodes[indexed.Tag]
+		i := sort.Search(len(tagged), func(i int) bool { return tagged[i].Index >= indexed.Index })
+		tagged = append(tagged, nil)
+		copy(tagged[i+1:], tagged[i:])
+		tagged[i] = indexed
+		id.nodes[indexed.Tag] = tagged
+	}
+}
+
+//
+// DOM: Drop the subtree of node, still linked in place, from the index
+//
+func (id *DOM) _unindex(node *DOMNode) {
+	id.rootNode = nil
+
+	subtree := node._indexedNodes(nil)
+	if len(subtree) == 0 {
+		return
+	}
+
+	for _, indexed := range subtree {
+		tagged := id.nodes[indexed.Tag]
+		i := sort.Search(len(tagged), func(i int) bool { return tagged[i].Index >= indexed.Index })
+		if i < len(tagged) && tagged[i] == indexed {
+			tagged = append(tagged[:i], tagged[i+1:]...)
+		}
+		if len(tagged) == 0 {
+			delete(id.nodes, indexed.Tag)
+		} else {
+			id.nodes[indexed.Tag] = tagged
+		}
+	}
+
+	position := node.Index - 1
+	id.document = append(id.document[:position], id.document[position+len(subtree):]...)
+	id._renumber(position)
+}
+
+//
+// DOM: Renumber the nodes from position on, Index is the 1-based document position
+//
+func (id *DOM) _renumber(position int) {
+	for i := position; i < len(id.document); i++ {
+		id.document[i].Index = i + 1
+	}
+	id.nodeCount = len(id.document)
+}
@@ -0,0 +1,140 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"testing"
+)
+
+//
+// the document order and tag index must match a fresh parse of the rendering
+//
+func checkDOMIndex(t *testing.T, d *DOM) {
+	tagged := 0
+	for i, node := range d.document {
+		if node.Index != i+1 || node.dom != d {
+			t.Errorf("expected index %d for %s, got %d", i+1, node.Tag, node.Index)
+		}
+		if i > 0 && node.Parent == nil {
+			t.Errorf("unexpected detached %s in the document", node.Tag)
+		}
+	}
+	for tag, nodes := range d.nodes {
+		for i, node := range nodes {
+			if node.Tag != tag || d.document[node.Index-1] != node || (i > 0 && nodes[i-1].Index >= node.Index) {
+				t.Errorf("unexpected %s in the tag index", tag)
+			}
+		}
+		tagged += len(nodes)
+	}
+	if tagged != len(d.document) || d.nodeCount != len(d.document) {
+		t.Errorf("expected %d tagged nodes, got %d", len(d.document), tagged)
+	}
+
+	reparsed := NewDOM()
+	reparsed.SetContents(d.Render())
+	for tag, nodes := range reparsed.nodes {
+		if len(d.nodes[tag]) != len(nodes) {
+			t.Errorf("expected %d %s nodes, got %d", len(nodes), tag, len(d.nodes[tag]))
+		}
+	}
+}
+
+func TestDOMMutation(t *testing.T) {
+	d := NewDOM()
+	d.SetContents(`<html><body><div id="a"><p id="p1">one</p><p id="p2">two</p></div><div id="b"><script>track()</script></div></body></html>`)
+
+	a := d.Find("div", DOMNodeAttributes{"id": "a"})[0]
+	b := d.Find("div", DOMNodeAttributes{"id": "b"})[0]
+	p1 := d.Find("p", DOMNodeAttributes{"id": "p1"})[0]
+	p2 := d.Find("p", DOMNodeAttributes{"id": "p2"})[0]
+
+	// move
+	if err := b.InsertBefore(p2, b.Children[0]); err != nil {
+		t.Fatal(err)
+	}
+	if p2.Parent != b || len(a.Children) != 1 || !d.IsDescendantNode(b, p2) || d.IsDescendantNode(a, p2) {
+		t.Errorf("expected p2 moved under b")
+	}
+	checkDOMIndex(t, d)
+
+	// insert a new node
+	span := NewDOMNode(0, nil, "span", nil)
+	span.SetText("new")
+	span.SetAttr("Class", "x")
+	if err := a.AppendChild(span); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Find("span", DOMNodeAttributes{"class": "x"})) != 1 {
+		t.Errorf("expected the appended span to be indexed")
+	}
+	checkDOMIndex(t, d)
+
+	// sanitize
+	d.Find("script", nil)[0].Remove()
+	if len(d.Find("script", nil)) != 0 {
+		t.Errorf("expected the script to be removed")
+	}
+	p1.RemoveAttr("id")
+	em := NewDOMNode(0, nil, "em", nil)
+	em.SetText("uno")
+	if err := p1.ReplaceWith(em); err != nil {
+		t.Fatal(err)
+	}
+	checkDOMIndex(t, d)
+
+	expected := `<html><head></head><body><div id="a"><em>uno</em><span class="x">new</span></div><div id="b"><p id="p2">two</p></div></body></html>`
+	if d.Render() != expected {
+		t.Errorf("expected %s, got %s", expected, d.Render())
+	}
+
+	b.SetText("cleared")
+	if len(d.Find("p", nil)) != 0 || b.Text() != "cleared" {
+		t.Errorf("expected SetText to drop the children")
+	}
+	checkDOMIndex(t, d)
+
+	// a node cannot contain itself
+	if err := p2.AppendChild(p2); err != ErrDOMHierarchy {
+		t.Errorf("expected a hierarchy error, got %v", err)
+	}
+	if err := a.AppendChild(d.RootNode()); err != ErrDOMHierarchy {
+		t.Errorf("expected a hierarchy error, got %v", err)
+	}
+}
+
+func TestDOMMutationSubtree(t *testing.T) {
+	d := NewDOM()
+	d.SetContents(`<html><body><ul id="a"><li>1</li><li>2<b>x</b></li></ul><p>tail</p></body></html>`)
+	other := NewDOM()
+	other.SetContents(`<html><body><ol><li>3<i>y</i></li><li>4</li></ol></body></html>`)
+
+	// a nested subtree moves between documents
+	ol := other.Find("ol", nil)[0]
+	ul := d.Find("ul", nil)[0]
+	if err := ul.Parent.InsertBefore(ol, ul); err != nil {
+		t.Fatal(err)
+	}
+	checkDOMIndex(t, d)
+	checkDOMIndex(t, other)
+	if len(other.Find("li", nil)) != 0 || len(d.Find("li", nil)) != 4 || d.Find("li", nil)[0].Text() != "3" {
+		t.Errorf("expected the list moved with its items in document order")
+	}
+
+	// and within the document, after its former following sibling
+	if err := ul.Parent.InsertBefore(ol, d.Find("p", nil)[0]); err != nil {
+		t.Fatal(err)
+	}
+	checkDOMIndex(t, d)
+	if d.Find("li", nil)[0].Text() != "1" || d.Find("i", nil)[0].Index <= d.Find("b", nil)[0].Index {
+		t.Errorf("expected the moved items renumbered")
+	}
+
+	ul.SetText("gone")
+	checkDOMIndex(t, d)
+	if len(d.Find("li", nil)) != 2 || len(d.Find("b", nil)) != 0 {
+		t.Errorf("expected the cleared items dropped from the index")
+	}
+}
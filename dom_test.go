@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"path"
 	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -39,9 +40,17 @@ func TestFind(t *testing.T) {
 	d := NewDOM()
 	d.SetContents(contents)
 
-	meta := d.Find("meta", nil)
-	if meta == nil {
-		t.Errorf("failed to find META")
+	// the only META sits in noscript, which is kept as text
+	if meta := d.Find("meta", nil); meta != nil {
+		t.Errorf("unexpected META parsed from noscript")
+	}
+	if len(d.Find("script", nil)) != 2 {
+		t.Errorf("failed to find SCRIPT")
+	}
+
+	redirect := NewHTML().ParseRedirect(d)
+	if !strings.HasPrefix(redirect, "http://example.com/cp/tdl4/index.asp?cmd=login&switchip=127.0.0.1&mac=aa:aa:aa:aa:aa:aa") {
+		t.Errorf("failed to find the noscript META redirect [%s]", redirect)
 	}
 }
 
@@ -131,3 +140,128 @@ func TestReaderText(t *testing.T) {
 	}
 
 }
+
+func TestTextNodeOrder(t *testing.T) {
+	d := NewDOM()
+	d.SetContents("<html><div id=\"a\"><b>bold</b> one <i>italic</i> two <u>under</u></div></html>")
+	p := d.Find("div", map[string]string{"id": "a"})
+	if len(p) != 1 {
+		t.Fatalf("failed to find node")
+	}
+	div := p[0]
+
+	if div.Text() != "one two" {
+		t.Errorf("failed to collect direct text [%s]", div.Text())
+	}
+	if div.ReaderText() != "bold one italic two under" {
+		t.Errorf("failed to order reader text [%s]", div.ReaderText())
+	}
+
+	if len(div.ChildNodes) != 5 || len(div.Children) != 3 {
+		t.Fatalf("expected 5 child nodes and 3 children, got %d %d", len(div.ChildNodes), len(div.Children))
+	}
+
+	b := div.Children[0]
+	if b.PrevSibling() != nil || !b.NextSibling().IsText() || b.NextSibling().Data != " one " {
+		t.Errorf("unexpected siblings of the first child")
+	}
+	if b.NextElementSibling().Tag != "i" || div.Children[2].PrevElementSibling().Tag != "i" {
+		t.Errorf("unexpected element siblings")
+	}
+	if div.Children[2].NextSibling() != nil {
+		t.Errorf("expected no sibling after the last child")
+	}
+}
+
+func TestEscapedMarkupText(t *testing.T) {
+	d := NewDOM()
+	d.SetContents("<html><body><p>a &lt;b&gt; c</p><div>x &lt;i&gt;y&lt;/i&gt; <span>z</span></div></body></html>")
+
+	p := d.Find("p", nil)[0]
+	if p.Text() != "a <b> c" || p.ReaderText() != "a <b> c" {
+		t.Errorf("failed to keep escaped markup as text [%s] [%s]", p.Text(), p.ReaderText())
+	}
+	if len(d.Find("b", nil)) != 0 || len(d.Find("i", nil)) != 0 {
+		t.Errorf("escaped markup parsed into elements")
+	}
+
+	div := d.Find("div", nil)[0]
+	if len(div.ChildNodes) != 2 || div.ChildNodes[0].Data != "x <i>y</i> " || div.ReaderText() != "x <i>y</i> z" {
+		t.Errorf("unexpected mixed content [%s]", div.ReaderText())
+	}
+}
+
+func TestRawText(t *testing.T) {
+	d := NewDOM()
+	d.SetContents(`<html><head><style>p > a { color: red; }</style></head><body><noscript><img src=pixel.gif>&amp;</noscript><div id="a">Hello <script>if (a < b) { document.write("<p>x</p>"); }</script>world<template><p class=later>later<template><b>inner</template></template></div></body></html>`)
+
+	script := d.Find("script", nil)
+	if len(script) != 1 || script[0].RawText() != `if (a < b) { document.write("<p>x</p>"); }` {
+		t.Fatalf("failed to keep the script verbatim")
+	}
+	if len(d.Find("p", nil)) != 0 {
+		t.Errorf("expected raw content not to be parsed into nodes")
+	}
+	if d.Find("style", nil)[0].RawText() != "p > a { color: red; }" {
+		t.Errorf("failed to keep the style verbatim")
+	}
+	// noscript and template content is the source as written, not re-serialized
+	if len(d.Find("img", nil)) != 0 || d.Find("noscript", nil)[0].RawText() != `<img src=pixel.gif>&amp;` {
+		t.Errorf("failed to keep the noscript verbatim [%s]", d.Find("noscript", nil)[0].RawText())
+	}
+	if templates := d.Find("template", nil); len(templates) != 1 || templates[0].RawText() != "<p class=later>later<template><b>inner</template>" {
+		t.Errorf("failed to keep the template verbatim [%s]", templates[0].RawText())
+	}
+
+	div := d.Find("div", map[string]string{"id": "a"})[0]
+	if div.ReaderText() != "Hello world" {
+		t.Errorf("expected raw content excluded from reader text [%s]", div.ReaderText())
+	}
+	if div.OuterHTML() != `<div id="a">Hello <script>if (a < b) { document.write("<p>x</p>"); }</script>world<template><p class=later>later<template><b>inner</template></template></div>` {
+		t.Errorf("failed to render raw content [%s]", div.OuterHTML())
+	}
+}
+
+func TestCommentNodes(t *testing.T) {
+	contents := loadData(t, "test_a.html")
+
+	d := NewDOM()
+	d.SetContents(contents)
+
+	document := d.Document()
+	if document == nil || d.RootNode().Parent != document {
+		t.Fatalf("expected the html node below the document node")
+	}
+
+	doctype := d.ChildFind(document, "doctype", nil)
+	if len(doctype) != 1 || doctype[0].Data != "html" || doctype[0].Attr("public") != "-//W3C//DTD XHTML 1.0 Transitional//EN" {
+		t.Errorf("failed to attach the doctype")
+	}
+
+	header, err := d.DumpHeader()
+	if err != nil || header.Method != "GET" || header.Status != 200 {
+		t.Errorf("failed to parse the dump header %+v %v", header, err)
+	}
+
+	d.SetContents("<html><body><p>a<!-- note -->b</p></body></html>")
+	comments := d.Find("comment", nil)
+	if len(comments) != 1 || comments[0].Data != " note " || comments[0].Parent.Tag != "p" {
+		t.Fatalf("failed to attach the comment")
+	}
+	if comments[0].Parent.Text() != "a b" || len(comments[0].Parent.Children) != 0 {
+		t.Errorf("expected the comment out of the text and children")
+	}
+	if _, err = d.DumpHeader(); err != ErrDumpHeaderNotFound {
+		t.Errorf("expected no dump header, got %v", err)
+	}
+
+	dumped := &DumpHeader{Method: "POST", URL: "http://example.com/a?b=c", Status: 302}
+	d.SetContents(dumped.String() + "<!DOCTYPE html><html></html>")
+	header, err = d.DumpHeader()
+	if err != nil || *header != *dumped {
+		t.Errorf("expected %+v, got %+v %v", dumped, header, err)
+	}
+	if d.Render() != "<!--\nMethod: POST\nURL: http://example.com/a?b=c\nStatus: 302\n--><!DOCTYPE html><html><head></head><body></body></html>" {
+		t.Errorf("failed to render the comment and doctype [%s]", d.Render())
+	}
+}
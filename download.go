@@ -0,0 +1,482 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	. "golog"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrDownloadChanged is returned when the resource changed during a segmented download
+	ErrDownloadChanged = errors.New("goweb: download resource changed")
+	// ErrDownloadChecksum is returned when the completed file does not match the checksum
+	ErrDownloadChecksum = errors.New("goweb: download checksum mismatch")
+	// ErrDownloadSize is returned when the completed file does not match the advertised size
+	ErrDownloadSize = errors.New("goweb: download size mismatch")
+	// ErrDownloadRange is returned when a partial response covers another range than requested
+	ErrDownloadRange = errors.New("goweb: download range mismatch")
+	// ErrDownloadChecksumType is returned for a ChecksumType other than md5, sha1, sha256 or sha512
+	ErrDownloadChecksumType = errors.New("goweb: unsupported download checksum type")
+)
+
+const (
+	DOWNLOAD_PART_SUFFIX = ".part"
+	DOWNLOAD_META_SUFFIX = ".part.json"
+	// DOWNLOAD_SAVE_INTERVAL is how often segment progress is persisted
+	DOWNLOAD_SAVE_INTERVAL = time.Second
+)
+
+//
+// DownloadOptions def
+// Checksum is a hex digest using ChecksumType (md5, sha1, sha256 or sha512),
+// sha256 when empty.
+// Segments > 1 splits the download into parallel ranges when the server allows it.
+//
+type DownloadOptions struct {
+	Segments     int
+	Checksum     string
+	ChecksumType string
+}
+
+//
+// download progress persisted next to the partial file
+//
+type _downloadMeta struct {
+	URL          string
+	ETag         string
+	LastModified string
+	Size         int64
+	Segments     []*_downloadSegment
+}
+
+//
+// Download: Strong validator for If-Range, weak ETags cannot guard a range
+//
+func (id *_downloadMeta) ifRange() string {
+	if len(id.ETag) > 0 && !strings.HasPrefix(id.ETag, "W/") {
+		return id.ETag
+	}
+
+	return id.LastModified
+}
+
+type _downloadSegment struct {
+	Start int64
+	End   int64
+	Done  int64
+}
+
+//
+// Download : Fetch urlString into path, resuming any partial download
+//
+func (id *HTTP) Download(urlString string, path string, options *DownloadOptions) (written int64, err error) {
+	if options == nil {
+		options = &DownloadOptions{}
+	}
+	if len(options.Checksum) > 0 {
+		if _, err = _downloadDigest(options.ChecksumType); err != nil {
+			return 0, err
+		}
+	}
+
+	partPath := path + DOWNLOAD_PART_SUFFIX
+	metaPath := path + DOWNLOAD_META_SUFFIX
+
+	// without a validator a resumed range could splice two versions of the resource
+	meta := _loadDownloadMeta(metaPath, urlString)
+	if meta != nil && len(meta.ifRange()) == 0 {
+		LogDebug("Download restarting, no validator for the partial file")
+		meta = nil
+	}
+	if meta == nil {
+		os.Remove(partPath)
+		meta = &_downloadMeta{URL: urlString, Size: -1}
+	}
+
+	if options.Segments > 1 && meta.Segments == nil && !_fileExists(partPath) {
+		id.planSegments(meta, options.Segments)
+	}
+
+	if meta.Segments != nil {
+		written, err = id.downloadSegments(meta, partPath, metaPath)
+	} else {
+		written, err = id.downloadSingle(meta, partPath, metaPath)
+	}
+	if err != nil {
+		return written, err
+	}
+
+	if err = _verifyDownload(partPath, meta.Size, options); err != nil {
+		os.Remove(partPath)
+		os.Remove(metaPath)
+		return written, err
+	}
+
+	os.Remove(metaPath)
+	err = os.Rename(partPath, path)
+
+	return written, err
+}
+
+//
+// Download: Probe the size and range support, then split into segments
+//
+func (id *HTTP) planSegments(meta *_downloadMeta, segments int) {
+	req, err := id.streamRequest(HTTP_HEAD, meta.URL)
+	if err != nil {
+		return
+	}
+
+	resp, err := id.streamClient().Do(req)
+	if err != nil {
+		LogWarn("Download HEAD failed, falling back to a single stream: " + err.Error())
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != 200 || resp.Header.Get("Accept-Ranges") != "bytes" || resp.ContentLength <= 0 {
+		return
+	}
+
+	meta.Size = resp.ContentLength
+	meta.ETag = resp.Header.Get("ETag")
+	meta.LastModified = resp.Header.Get("Last-Modified")
+
+	chunk := meta.Size / int64(segments)
+	if chunk == 0 {
+		return
+	}
+	for i := 0; i < segments; i++ {
+		segment := &_downloadSegment{Start: int64(i) * chunk, End: int64(i+1)*chunk - 1}
+		if i == segments-1 {
+			segment.End = meta.Size - 1
+		}
+		meta.Segments = append(meta.Segments, segment)
+	}
+}
+
+//
+// Download: A ranged GET validated against the stored ETag or Last-Modified
+//
+func (id *HTTP) rangeRequest(meta *_downloadMeta, start int64, end int64) (resp *http.Response, err error) {
+	req, err := id.streamRequest(HTTP_GET, meta.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if start > 0 || end >= 0 {
+		rangeValue := "bytes=" + strconv.FormatInt(start, 10) + "-"
+		if end >= 0 {
+			rangeValue += strconv.FormatInt(end, 10)
+		}
+		req.Header.Set("Range", rangeValue)
+
+		// a changed resource answers 200 with the full body instead of 206
+		if validator := meta.ifRange(); len(validator) > 0 {
+			req.Header.Set("If-Range", validator)
+		}
+	}
+
+	if id.RateLimiter != nil {
+		id.RateLimiter.Acquire(req.URL.Host)
+		defer id.RateLimiter.Release(req.URL.Host)
+	}
+
+	return id.doWithTimeouts(id.streamClient(), req)
+}
+
+//
+// Download: Single stream, appending to the partial file when the server honors the range
+//
+func (id *HTTP) downloadSingle(meta *_downloadMeta, partPath string, metaPath string) (written int64, err error) {
+	offset := int64(0)
+	if info, statErr := os.Stat(partPath); statErr == nil {
+		offset = info.Size()
+	}
+
+	resp, err := id.rangeRequest(meta, offset, -1)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if start, _, ok := _contentRange(resp.Header.Get("Content-Range")); !ok || start != offset {
+			return 0, ErrDownloadRange
+		}
+		LogDebugf("Download resuming at %d", offset)
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		offset = 0
+		flags |= os.O_TRUNC
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the partial file is already complete
+		if meta.Size < 0 || meta.Size == offset {
+			meta.Size = offset
+			return 0, nil
+		}
+		os.Remove(partPath)
+		os.Remove(metaPath)
+		return 0, fmt.Errorf("goweb: download status %d", resp.StatusCode)
+	default:
+		return 0, fmt.Errorf("goweb: download status %d", resp.StatusCode)
+	}
+
+	if resp.StatusCode == http.StatusOK || (len(meta.ETag) == 0 && len(meta.LastModified) == 0) {
+		meta.ETag = resp.Header.Get("ETag")
+		meta.LastModified = resp.Header.Get("Last-Modified")
+	}
+	if resp.ContentLength >= 0 {
+		meta.Size = offset + resp.ContentLength
+	}
+	_saveDownloadMeta(metaPath, meta)
+
+	file, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	written, err = io.Copy(file, resp.Body)
+
+	return written, err
+}
+
+//
+// Download: Parallel ranged streams written in place, incomplete segments resume
+//
+func (id *HTTP) downloadSegments(meta *_downloadMeta, partPath string, metaPath string) (written int64, err error) {
+	file, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+
+	// the plan and the progress are on disk as the segments advance, a killed process resumes
+	_saveDownloadMeta(metaPath, meta)
+	saved := time.Now()
+	advance := func(segment *_downloadSegment, count int64) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		segment.Done += count
+		written += count
+		if time.Since(saved) >= DOWNLOAD_SAVE_INTERVAL {
+			_saveDownloadMeta(metaPath, meta)
+			saved = time.Now()
+		}
+	}
+
+	for _, segment := range meta.Segments {
+		if segment.Start+segment.Done > segment.End {
+			continue
+		}
+
+		wg.Add(1)
+		go func(segment *_downloadSegment) {
+			defer wg.Done()
+
+			segmentErr := id.downloadSegment(meta, segment, file, advance)
+
+			lock.Lock()
+			if segmentErr != nil && firstErr == nil {
+				firstErr = segmentErr
+			}
+			lock.Unlock()
+		}(segment)
+	}
+	wg.Wait()
+
+	if firstErr == ErrDownloadChanged {
+		// start over on the next call
+		os.Remove(partPath)
+		os.Remove(metaPath)
+		return written, firstErr
+	}
+
+	// progress is kept even on failure so the next call resumes
+	_saveDownloadMeta(metaPath, meta)
+
+	return written, firstErr
+}
+
+//
+// Download: Fetch the remainder of one segment into the file at its offset
+// The written bytes are reported to advance, which owns the segment progress.
+//
+func (id *HTTP) downloadSegment(meta *_downloadMeta, segment *_downloadSegment, file *os.File, advance func(*_downloadSegment, int64)) error {
+	start := segment.Start + segment.Done
+
+	resp, err := id.rangeRequest(meta, start, segment.End)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		// If-Range failed, the segments no longer belong to one resource
+		return ErrDownloadChanged
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("goweb: segment status %d", resp.StatusCode)
+	}
+	if rangeStart, rangeEnd, ok := _contentRange(resp.Header.Get("Content-Range")); !ok || rangeStart != start || rangeEnd > segment.End {
+		return ErrDownloadRange
+	}
+
+	// never past the segment, whatever the server sends
+	body := io.LimitReader(resp.Body, segment.End-start+1)
+	offset := start
+	buffer := make([]byte, 32*1024)
+	for {
+		count, readErr := body.Read(buffer)
+		if count > 0 {
+			if _, err = file.WriteAt(buffer[:count], offset); err != nil {
+				return err
+			}
+			offset += int64(count)
+			advance(segment, int64(count))
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+//
+// Download: The first and last byte of a Content-Range (RFC 7233 4.2)
+//
+func _contentRange(value string) (start int64, end int64, ok bool) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "bytes ") {
+		return 0, 0, false
+	}
+
+	span := strings.TrimSpace(strings.TrimPrefix(value, "bytes "))
+	if slash := strings.Index(span, "/"); slash > -1 {
+		span = span[:slash]
+	}
+	bounds := strings.SplitN(span, "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, false
+	}
+
+	start, startErr := strconv.ParseInt(bounds[0], 10, 64)
+	end, endErr := strconv.ParseInt(bounds[1], 10, 64)
+	if startErr != nil || endErr != nil || start < 0 || end < start {
+		return 0, 0, false
+	}
+
+	return start, end, true
+}
+
+//
+// Download: Check the size and checksum of the completed file
+//
+func _verifyDownload(partPath string, size int64, options *DownloadOptions) error {
+	if size >= 0 {
+		if info, err := os.Stat(partPath); err != nil || info.Size() != size {
+			return ErrDownloadSize
+		}
+	}
+
+	if len(options.Checksum) == 0 {
+		return nil
+	}
+
+	digest, err := _downloadDigest(options.ChecksumType)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(partPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err = io.Copy(digest, file); err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(hex.EncodeToString(digest.Sum(nil)), strings.TrimSpace(options.Checksum)) {
+		return ErrDownloadChecksum
+	}
+
+	return nil
+}
+
+//
+// Download: The hash of a ChecksumType, sha256 when empty
+//
+func _downloadDigest(checksumType string) (hash.Hash, error) {
+	switch strings.ToLower(strings.TrimSpace(checksumType)) {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256", "":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	}
+
+	return nil, ErrDownloadChecksumType
+}
+
+func _loadDownloadMeta(metaPath string, urlString string) *_downloadMeta {
+	data, err := ioutil.ReadFile(metaPath)
+	if err != nil {
+		return nil
+	}
+
+	meta := &_downloadMeta{}
+	if json.Unmarshal(data, meta) != nil || meta.URL != urlString {
+		return nil
+	}
+
+	return meta
+}
+
+func _saveDownloadMeta(metaPath string, meta *_downloadMeta) {
+	data, err := json.Marshal(meta)
+	if err == nil {
+		err = ioutil.WriteFile(metaPath, data, 0644)
+	}
+	if err != nil {
+		LogError(err)
+	}
+}
+
+func _fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
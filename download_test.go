@@ -0,0 +1,207 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newDownloadTestServer(payload []byte, ranges *[]string) *httptest.Server {
+	var lock sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		*ranges = append(*ranges, r.Header.Get("Range"))
+		lock.Unlock()
+		w.Header().Set("ETag", "\"v1\"")
+		http.ServeContent(w, r, "payload.bin", time.Unix(0, 0), bytes.NewReader(payload))
+	}))
+}
+
+func TestDownloadResume(t *testing.T) {
+	payload := []byte(strings.Repeat("0123456789", 1000))
+	ranges := []string{}
+	server := newDownloadTestServer(payload, &ranges)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "payload.bin")
+	ioutil.WriteFile(path+DOWNLOAD_PART_SUFFIX, payload[:4000], 0644)
+	_saveDownloadMeta(path+DOWNLOAD_META_SUFFIX, &_downloadMeta{URL: server.URL, ETag: "\"v1\"", Size: -1})
+
+	x := NewHTTP()
+	written, err := x.Download(server.URL, path, nil)
+	if err != nil || written != 6000 {
+		t.Fatalf("Download written %d %v", written, err)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=4000-" {
+		t.Errorf("Download ranges %v", ranges)
+	}
+
+	contents, _ := ioutil.ReadFile(path)
+	if !bytes.Equal(contents, payload) {
+		t.Errorf("Download contents length %d vs expected %d", len(contents), len(payload))
+	}
+}
+
+func TestDownloadResumeWithoutValidator(t *testing.T) {
+	payload := []byte(strings.Repeat("0123456789", 1000))
+	ranges := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(payload))
+	}))
+	defer server.Close()
+
+	// the partial file came from an earlier version nothing can tell apart
+	path := filepath.Join(t.TempDir(), "payload.bin")
+	ioutil.WriteFile(path+DOWNLOAD_PART_SUFFIX, bytes.Repeat([]byte("x"), 4000), 0644)
+	_saveDownloadMeta(path+DOWNLOAD_META_SUFFIX, &_downloadMeta{URL: server.URL, ETag: "W/\"v1\"", Size: -1})
+
+	x := NewHTTP()
+	written, err := x.Download(server.URL, path, nil)
+	if err != nil || written != int64(len(payload)) {
+		t.Fatalf("Download written %d %v", written, err)
+	}
+	if len(ranges) != 1 || ranges[0] != "" {
+		t.Errorf("expected a restart without a range, got %v", ranges)
+	}
+
+	contents, _ := ioutil.ReadFile(path)
+	if !bytes.Equal(contents, payload) {
+		t.Errorf("Download spliced a stale partial file")
+	}
+}
+
+func TestDownloadSegments(t *testing.T) {
+	payload := []byte(strings.Repeat("abcdefghij", 1000))
+	digest := sha256.Sum256(payload)
+	ranges := []string{}
+	server := newDownloadTestServer(payload, &ranges)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "payload.bin")
+	x := NewHTTP()
+	_, err := x.Download(server.URL, path, &DownloadOptions{Segments: 4, Checksum: hex.EncodeToString(digest[:])})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// HEAD plus one ranged GET per segment
+	if len(ranges) != 5 {
+		t.Errorf("Download requests %v", ranges)
+	}
+
+	contents, _ := ioutil.ReadFile(path)
+	if !bytes.Equal(contents, payload) {
+		t.Errorf("Download contents length %d vs expected %d", len(contents), len(payload))
+	}
+}
+
+func TestDownloadChecksum(t *testing.T) {
+	ranges := []string{}
+	server := newDownloadTestServer([]byte("payload"), &ranges)
+	defer server.Close()
+
+	x := NewHTTP()
+	_, err := x.Download(server.URL, filepath.Join(t.TempDir(), "payload.bin"), &DownloadOptions{Checksum: "00", ChecksumType: "md5"})
+	if err != ErrDownloadChecksum {
+		t.Errorf("Checksum mismatch not detected %v", err)
+	}
+}
+
+func TestDownloadRangeMismatch(t *testing.T) {
+	payload := []byte(strings.Repeat("0123456789", 1000))
+	// a server answering every range from the start of the resource
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", "\"v1\"")
+		w.Header().Set("Accept-Ranges", "bytes")
+		if len(r.Header.Get("Range")) == 0 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+			w.Write(payload)
+			return
+		}
+		w.Header().Set("Content-Range", "bytes 0-999/"+strconv.Itoa(len(payload)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(payload[:1000])
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "payload.bin")
+	ioutil.WriteFile(path+DOWNLOAD_PART_SUFFIX, payload[:4000], 0644)
+	_saveDownloadMeta(path+DOWNLOAD_META_SUFFIX, &_downloadMeta{URL: server.URL, ETag: "\"v1\"", Size: -1})
+
+	x := NewHTTP()
+	if _, err := x.Download(server.URL, path, nil); err != ErrDownloadRange {
+		t.Errorf("Resume range mismatch not detected %v", err)
+	}
+	if part, _ := ioutil.ReadFile(path + DOWNLOAD_PART_SUFFIX); !bytes.Equal(part, payload[:4000]) {
+		t.Errorf("Resume range mismatch altered the part file")
+	}
+
+	segmented := filepath.Join(t.TempDir(), "payload.bin")
+	if _, err := x.Download(server.URL, segmented, &DownloadOptions{Segments: 4}); err != ErrDownloadRange {
+		t.Errorf("Segment range mismatch not detected %v", err)
+	}
+}
+
+func TestDownloadSegmentOverrun(t *testing.T) {
+	payload := []byte(strings.Repeat("abcdefghij", 1000))
+	metaSaved := true
+	var lock sync.Mutex
+	path := filepath.Join(t.TempDir(), "payload.bin")
+	// the right Content-Range followed by more bytes than requested
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", "\"v1\"")
+		w.Header().Set("Accept-Ranges", "bytes")
+		var start, end int
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err != nil {
+			w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+			return
+		}
+		lock.Lock()
+		metaSaved = metaSaved && _fileExists(path+DOWNLOAD_META_SUFFIX)
+		lock.Unlock()
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(payload)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(payload[start : end+1])
+		w.Write(bytes.Repeat([]byte("!"), 500))
+	}))
+	defer server.Close()
+
+	x := NewHTTP()
+	if _, err := x.Download(server.URL, path, &DownloadOptions{Segments: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if !metaSaved {
+		t.Errorf("Download plan not persisted before the segments")
+	}
+
+	contents, _ := ioutil.ReadFile(path)
+	if !bytes.Equal(contents, payload) {
+		t.Errorf("Download wrote past a segment")
+	}
+}
+
+func TestDownloadChecksumType(t *testing.T) {
+	ranges := []string{}
+	server := newDownloadTestServer([]byte("payload"), &ranges)
+	defer server.Close()
+
+	x := NewHTTP()
+	_, err := x.Download(server.URL, filepath.Join(t.TempDir(), "payload.bin"), &DownloadOptions{Checksum: "00", ChecksumType: "sha-256"})
+	if err != ErrDownloadChecksumType || len(ranges) != 0 {
+		t.Errorf("Unknown checksum type %v after %d requests", err, len(ranges))
+	}
+}
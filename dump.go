@@ -0,0 +1,90 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrDumpHeaderNotFound is returned when a document does not start with a goweb dump header
+var ErrDumpHeaderNotFound = errors.New("goweb: dump header not found")
+
+//
+// DumpHeader def
+// The exchange recorded in the leading comment of the goweb dump files.
+//
+type DumpHeader struct {
+	Method string
+	URL    string
+	Status int
+}
+
+//
+// DumpHeader: The leading comment written ahead of the dumped contents
+//
+func (id *DumpHeader) String() string {
+	return "<!--\nMethod: " + id.Method + "\nURL: " + id.URL + "\nStatus: " + strconv.Itoa(id.Status) + "\n-->\n\n"
+}
+
+//
+// ParseDumpHeader : Read the Method, URL and Status lines of a dump header comment
+//
+func ParseDumpHeader(comment string) (header *DumpHeader, err error) {
+	comment = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(comment), "<!--"), "-->")
+
+	header = &DumpHeader{}
+	found := false
+	for _, line := range strings.Split(comment, "\n") {
+		idx := strings.Index(line, ":")
+		if idx < 0 {
+			continue
+		}
+
+		value := strings.TrimSpace(line[idx+1:])
+		switch strings.TrimSpace(line[:idx]) {
+		case "Method":
+			header.Method = value
+			found = true
+		case "URL":
+			header.URL = value
+			found = true
+		case "Status":
+			if header.Status, err = strconv.Atoi(value); err != nil {
+				return nil, err
+			}
+			found = true
+		}
+	}
+
+	if !found {
+		return nil, ErrDumpHeaderNotFound
+	}
+
+	return header, nil
+}
+
+//
+// DumpHeader : The dump header of a document read back from a goweb dump file
+//
+func (id *DOM) DumpHeader() (*DumpHeader, error) {
+	document := id.Document()
+	if document == nil {
+		return nil, ErrDumpHeaderNotFound
+	}
+
+	for _, node := range document.ChildNodes {
+		if node.Tag == DOM_COMMENT_TAG {
+			return ParseDumpHeader(node.Data)
+		}
+		if !node.IsText() {
+			// the header precedes the doctype and the html
+			break
+		}
+	}
+
+	return nil, ErrDumpHeaderNotFound
+}
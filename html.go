@@ -6,6 +6,8 @@ package goweb
 
 import (
 	. "golog"
+	"net/url"
+	"sort"
 	"strings"
 )
 
@@ -21,6 +23,13 @@ func NewHTML() *HTML {
 
 func (self *HTML) ParseRedirect(d *DOM) (result string) {
 	meta := d.Find("meta", nil)
+	// portals keep the refresh for browsers without scripting in noscript, which stays unparsed
+	noscripts := d.Find("noscript", nil)
+	for i := 0; len(meta) == 0 && i < len(noscripts); i++ {
+		fallback := NewDOM()
+		fallback.SetContents(noscripts[i].RawText())
+		meta = fallback.Find("meta", nil)
+	}
 	if len(meta) > 0 {
 		LogDebug("META found")
 		value := meta[0].Attr("content")
@@ -43,3 +52,139 @@ func (self *HTML) ParseRedirect(d *DOM) (result string) {
 
 	return result
 }
+
+//
+// HTMLForm def
+//
+type HTMLForm struct {
+	Node   *DOMNode
+	Action string
+	Method string
+	Values url.Values
+	Types  map[string]string
+}
+
+//
+// ParseForms : The forms of the DOM with their default submission values
+// The action is resolved against pageURL, which may be nil.
+//
+func (self *HTML) ParseForms(d *DOM, pageURL *url.URL) (result []*HTMLForm) {
+	for _, node := range d.Find("form", nil) {
+		form := &HTMLForm{Node: node, Method: HTTP_GET, Values: url.Values{}, Types: map[string]string{}}
+
+		if method := strings.ToUpper(strings.TrimSpace(node.Attr("method"))); method == HTTP_POST {
+			form.Method = HTTP_POST
+		}
+
+		form.Action = strings.TrimSpace(node.Attr("action"))
+		if pageURL != nil {
+			if action, err := pageURL.Parse(form.Action); err == nil {
+				form.Action = action.String()
+			}
+		}
+
+		submitted := false
+		for _, input := range d.ChildFind(node, "input", nil) {
+			name := input.Attr("name")
+			if len(name) == 0 {
+				continue
+			}
+			inputType := strings.ToLower(input.Attr("type"))
+			if len(inputType) == 0 {
+				inputType = "text"
+			}
+			form.Types[name] = inputType
+
+			switch inputType {
+			case "checkbox", "radio":
+				if _, checked := input.Attributes["checked"]; checked {
+					value := input.Attr("value")
+					if len(value) == 0 {
+						value = "on"
+					}
+					form.Values.Add(name, value)
+				}
+			case "submit", "image":
+				// only the first (default) submit button is successful
+				if !submitted {
+					submitted = true
+					form.Values.Add(name, input.Attr("value"))
+				}
+			case "button", "reset", "file":
+			default:
+				form.Values.Add(name, input.Attr("value"))
+			}
+		}
+
+		for _, textarea := range d.ChildFind(node, "textarea", nil) {
+			if name := textarea.Attr("name"); len(name) > 0 {
+				form.Types[name] = "textarea"
+				form.Values.Add(name, textarea.Text())
+			}
+		}
+
+		for _, sel := range d.ChildFind(node, "select", nil) {
+			name := sel.Attr("name")
+			if len(name) == 0 {
+				continue
+			}
+			form.Types[name] = "select"
+			options := d.ChildFind(sel, "option", nil)
+			var chosen *DOMNode
+			for _, option := range options {
+				if _, selected := option.Attributes["selected"]; selected {
+					chosen = option
+					break
+				}
+			}
+			// without a selection the first option is submitted
+			if chosen == nil && len(options) > 0 {
+				chosen = options[0]
+			}
+			if chosen != nil {
+				value, ok := chosen.Attributes["value"]
+				if !ok {
+					value = chosen.Text()
+				}
+				form.Values.Add(name, value)
+			}
+		}
+
+		result = append(result, form)
+	}
+
+	return result
+}
+
+//
+// Fields : The names of the form fields of the given input type
+//
+func (self *HTMLForm) Fields(inputType string) (result []string) {
+	for name, fieldType := range self.Types {
+		if fieldType == inputType {
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+//
+// SubmitForm : Submit the form values to the form action
+//
+func (id *HTTP) SubmitForm(form *HTMLForm) (result string) {
+	if form.Method == HTTP_POST {
+		return id.PostString(form.Action, CONTENT_TYPE_FORM, form.Values.Encode())
+	}
+
+	action, err := url.Parse(form.Action)
+	if err != nil {
+		LogError(err)
+		id.err = err
+		return ""
+	}
+	action.RawQuery = form.Values.Encode()
+
+	return id.Get(action.String())
+}
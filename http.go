@@ -6,6 +6,7 @@ package goweb
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
@@ -13,18 +14,30 @@ import (
 	. "golog"
 	"io/ioutil"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
-	"strconv"
 	"strings"
+	"time"
 )
 
 const (
-	HTTP_GET  = "GET"
-	HTTP_POST = "POST"
+	HTTP_GET     = "GET"
+	HTTP_POST    = "POST"
+	HTTP_PUT     = "PUT"
+	HTTP_PATCH   = "PATCH"
+	HTTP_DELETE  = "DELETE"
+	HTTP_HEAD    = "HEAD"
+	HTTP_OPTIONS = "OPTIONS"
 )
 
+// HTTP_MAX_REDIRECTS caps the redirect hops followed for one request
+const HTTP_MAX_REDIRECTS = 10
+
+// ErrTooManyRedirects is returned when a request redirects more than HTTP_MAX_REDIRECTS times
+var ErrTooManyRedirects = errors.New("goweb: too many redirects")
+
 const (
 	CONTENT_TYPE_NONE       = ""
 	CONTENT_TYPE_FORM       = "application/x-www-form-urlencoded"
@@ -47,8 +60,27 @@ type HTTP struct {
 	req         *http.Request
 	resp        *http.Response
 	RawContents []byte
-
-	gaeRequest *http.Request
+	TLS         *TLSConfig
+	Cache       *HTTPCache
+	RateLimiter *RateLimiter
+	Robots      *RobotsCache
+	UserAgent   string
+	Header      http.Header
+	// DisableRedirects returns 3xx and meta / script redirect pages as is
+	DisableRedirects bool
+	TimingHooks      []TimingHook
+	// Timeouts nil applies NewTimeouts
+	Timeouts *Timeouts
+
+	gaeRequest     *http.Request
+	err            error
+	reqContentType string
+	reqContent     []byte
+	cacheEntry     *CacheEntry
+	requestTime    time.Time
+	timing         *RequestTiming
+	trace          *_timingTrace
+	redirectDepth  int
 }
 
 //
@@ -69,6 +101,13 @@ func (id *HTTP) Contents() string {
 	return string(id.RawContents)
 }
 
+//
+// LastError : The error, if any, raised by the last request
+//
+func (id *HTTP) LastError() error {
+	return id.err
+}
+
 //
 // Tidy the URL such that it is minimally valid
 //
@@ -148,11 +187,120 @@ func (id *HTTP) PostData(urlString string, contentType string, contentBytes []by
 // Fetch: POST request
 //
 func (id *HTTP) PostContent(urlString string, contentType string, content *bytes.Buffer) (result string) {
-	id.Method = HTTP_POST
+	return id.DoContent(HTTP_POST, urlString, contentType, content)
+}
+
+//
+// Fetch: PUT request
+//
+func (id *HTTP) Put(urlString string, args map[string]string) (result string) {
+	content := _formatArgs(args)
+	result = id.PutContent(urlString, CONTENT_TYPE_NONE, content)
+
+	return result
+}
+
+func (id *HTTP) PutString(urlString string, contentType string, contentString string) (result string) {
+	content := bytes.NewBuffer([]byte(contentString))
+	result = id.PutContent(urlString, contentType, content)
+
+	return result
+}
+
+func (id *HTTP) PutData(urlString string, contentType string, contentBytes []byte) (result string) {
+	content := bytes.NewBuffer(contentBytes)
+	result = id.PutContent(urlString, contentType, content)
+
+	return result
+}
+
+func (id *HTTP) PutContent(urlString string, contentType string, content *bytes.Buffer) (result string) {
+	return id.DoContent(HTTP_PUT, urlString, contentType, content)
+}
+
+//
+// Fetch: PATCH request
+//
+func (id *HTTP) Patch(urlString string, args map[string]string) (result string) {
+	content := _formatArgs(args)
+	result = id.PatchContent(urlString, CONTENT_TYPE_NONE, content)
+
+	return result
+}
+
+func (id *HTTP) PatchString(urlString string, contentType string, contentString string) (result string) {
+	content := bytes.NewBuffer([]byte(contentString))
+	result = id.PatchContent(urlString, contentType, content)
+
+	return result
+}
+
+func (id *HTTP) PatchData(urlString string, contentType string, contentBytes []byte) (result string) {
+	content := bytes.NewBuffer(contentBytes)
+	result = id.PatchContent(urlString, contentType, content)
+
+	return result
+}
+
+func (id *HTTP) PatchContent(urlString string, contentType string, content *bytes.Buffer) (result string) {
+	return id.DoContent(HTTP_PATCH, urlString, contentType, content)
+}
+
+//
+// Fetch: DELETE request
+//
+func (id *HTTP) Delete(urlString string) (result string) {
+	return id.DoContent(HTTP_DELETE, urlString, CONTENT_TYPE_NONE, nil)
+}
+
+func (id *HTTP) DeleteString(urlString string, contentType string, contentString string) (result string) {
+	content := bytes.NewBuffer([]byte(contentString))
+	result = id.DeleteContent(urlString, contentType, content)
+
+	return result
+}
+
+func (id *HTTP) DeleteData(urlString string, contentType string, contentBytes []byte) (result string) {
+	content := bytes.NewBuffer(contentBytes)
+	result = id.DeleteContent(urlString, contentType, content)
+
+	return result
+}
+
+func (id *HTTP) DeleteContent(urlString string, contentType string, content *bytes.Buffer) (result string) {
+	return id.DoContent(HTTP_DELETE, urlString, contentType, content)
+}
+
+//
+// Fetch: HEAD request, the response body is never read
+//
+func (id *HTTP) Head(urlString string) (result string) {
+	return id.DoContent(HTTP_HEAD, urlString, CONTENT_TYPE_NONE, nil)
+}
+
+//
+// Fetch: OPTIONS request
+//
+func (id *HTTP) Options(urlString string) (result string) {
+	return id.DoContent(HTTP_OPTIONS, urlString, CONTENT_TYPE_NONE, nil)
+}
+
+//
+// Fetch: Request with an arbitrary method and an untyped body
+//
+func (id *HTTP) Do(method string, urlString string, content *bytes.Buffer) (result string) {
+	return id.DoContent(method, urlString, CONTENT_TYPE_NONE, content)
+}
+
+//
+// Fetch: Request with an arbitrary method and a typed body
+//
+func (id *HTTP) DoContent(method string, urlString string, contentType string, content *bytes.Buffer) (result string) {
+	id.Method = strings.ToUpper(method)
 	id.tidyURL(urlString)
 
 	result = id.prepareAndExecuteRequest(contentType, content)
-	LogDebugf("POST status %d", id.Status())
+	LogDebugf("%s status %d", id.Method, id.Status())
 
 	return result
 }
@@ -207,21 +355,26 @@ func _formatArgs(args map[string]string) (content *bytes.Buffer) {
 func (id *HTTP) prepareAndExecuteRequest(contentType string, content *bytes.Buffer) string {
 	LogDebug(id.Method + ": " + id.URLString())
 
+	// redirects re-enter here, only the outermost call reports the timing
+	id.redirectDepth++
+	defer func() {
+		id.redirectDepth--
+		if id.redirectDepth == 0 {
+			id.observeTiming()
+		}
+	}()
+
 	RedirectAttemptedError := errors.New("redirect")
 
 	client := GetClient(id.gaeRequest)
+	client.Timeout = id.timeouts().total()
 	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 		return RedirectAttemptedError
 	}
 	client.Jar = id.cookieJar
 
-	// if we're proxying, we're going to disable the TLS cert verification
-	if DetectProxy() {
-		id.ProxyURL, _ = url.Parse("http://127.0.0.1:8080")
-		client.Transport = &http.Transport{
-			Proxy:           http.ProxyURL(id.ProxyURL),
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
+	if transport := id.transport(); transport != nil {
+		client.Transport = transport
 	}
 
 	// ensure we don't pass a nil ByteBuffer into http.NewRequest
@@ -229,7 +382,20 @@ func (id *HTTP) prepareAndExecuteRequest(contentType string, content *bytes.Buff
 		content = bytes.NewBuffer([]byte(""))
 	}
 
-	id.req, _ = http.NewRequest(id.Method, id.URLString(), content)
+	// retain the body so 307 / 308 redirects can replay it
+	id.reqContentType = contentType
+	id.reqContent = append([]byte(nil), content.Bytes()...)
+
+	var err error
+	id.timing = nil
+	id.req, err = http.NewRequest(id.Method, id.URLString(), content)
+	if err != nil {
+		LogError(err)
+		id.err = err
+		id.resp = nil
+		id.RawContents = nil
+		return ""
+	}
 
 	if len(id.URL.Host) > 0 {
 		id.setRequestHeader("Host", id.URL.Host)
@@ -241,43 +407,243 @@ func (id *HTTP) prepareAndExecuteRequest(contentType string, content *bytes.Buff
 
 	id.setRequestHeader("Connection", "close")
 
-	// randomize the user agent
-	uaStr := HTTP_USER_AGENT[rand.Intn(len(HTTP_USER_AGENT))]
-	id.setRequestHeader("User-Agent", uaStr)
+	id.setRequestHeader("User-Agent", id.userAgent())
+
+	// caller supplied headers replace the defaults
+	for key, values := range id.Header {
+		id.req.Header[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+	}
+	uaStr := id.req.Header.Get("User-Agent")
+
+	id.startTiming()
 
 	// id.setRequestHeader("Referer", referrer)
-	var err error
-	id.resp, err = client.Do(id.req)
+	id.err = nil
 
-	if err != nil && !strings.HasSuffix(err.Error(), " redirect") {
+	if err = id.robotsCheck(uaStr); err != nil {
+		LogError(err)
+		id.finishTiming(err)
+		id.err = err
+		id.resp = nil
+		id.RawContents = nil
+		return ""
+	}
+
+	// a fresh cache entry (or offline mode) short circuits the network
+	cached, err := id.cacheRequest()
+	if err != nil {
 		LogError(err)
+		id.finishTiming(err)
+		id.err = err
 		return ""
+	}
+
+	if !cached {
+		err = id.executeRequest(client)
+		id.finishTiming(err)
+		if err != nil {
+			LogError(err)
+			id.err = err
+			return ""
+		}
+
+		id.cacheResponse()
 	} else {
-		bytes, _ := ioutil.ReadAll(id.resp.Body)
-		id.RawContents = bytes
+		id.timing.Cached = true
+		id.finishTiming(nil)
 	}
-	defer id.resp.Body.Close()
 
 	// at this point we have the request and response, save a record if configured
-	output := "<!--\nMethod: " + id.Method + "\nURL: " + id.URLString() + "\nStatus: " + strconv.Itoa(id.Status()) + "\n-->\n\n" + id.Contents()
+	header := &DumpHeader{Method: id.Method, URL: id.URLString(), Status: id.Status()}
+	output := header.String() + id.Contents()
 	LogDumpFile("goweb", output)
 
-	// handle redirects
-	id.handleRedirection()
+	// handle redirects, the final exchange accumulates the time spent on each hop
+	hop := id.timing
+	if !id.DisableRedirects {
+		id.handleRedirection()
+	}
+	if id.timing != hop && id.timing != nil {
+		id.timing.Redirects++
+		id.timing.RedirectTime += hop.Total
+	}
 
 	return id.Contents()
 }
 
+//
+// Fetch: The configured user agent, otherwise a randomized one
+//
+func (id *HTTP) userAgent() string {
+	if len(id.UserAgent) > 0 {
+		return id.UserAgent
+	}
+
+	return HTTP_USER_AGENT[rand.Intn(len(HTTP_USER_AGENT))]
+}
+
+//
+// Fetch: Execute the prepared request over the network and read the response body
+//
+func (id *HTTP) executeRequest(client *http.Client) (err error) {
+	// the host slot is released before any redirect is followed
+	if id.RateLimiter != nil {
+		id.RateLimiter.Acquire(id.URL.Host)
+		defer id.RateLimiter.Release(id.URL.Host)
+	}
+
+	id.resp, err = id.doWithTimeouts(client, id.req)
+
+	if err != nil && !strings.HasSuffix(err.Error(), " redirect") {
+		return err
+	}
+	defer id.resp.Body.Close()
+
+	if id.Method == HTTP_HEAD {
+		// HEAD responses carry no body
+		id.RawContents = nil
+	} else {
+		bodyStart := time.Now()
+		bytes, readErr := ioutil.ReadAll(id.resp.Body)
+		id.RawContents = bytes
+		id.timing.BodyRead = time.Since(bodyStart)
+		// a redirect response arrives with its body already closed
+		if readErr != nil && err == nil {
+			return readErr
+		}
+	}
+
+	return nil
+}
+
+//
+// Fetch: A client for streamed responses sharing the cookies, proxy and TLS settings
+// Unlike the buffered requests, redirects are followed by net/http.
+//
+func (id *HTTP) streamClient() *http.Client {
+	client := GetClient(id.gaeRequest)
+	client.Jar = id.cookieJar
+	// streams run as long as the body keeps flowing
+	client.Timeout = 0
+	if transport := id.transport(); transport != nil {
+		client.Transport = transport
+	}
+
+	return client
+}
+
+//
+// Fetch: A request for a streamed response carrying the user agent and caller headers
+//
+func (id *HTTP) streamRequest(method string, urlString string) (req *http.Request, err error) {
+	req, err = http.NewRequest(method, urlString, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", id.userAgent())
+	for key, values := range id.Header {
+		req.Header[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+	}
+
+	return req, nil
+}
+
+//
+// Transport: Build a transport for the proxy, TLS and timeout settings, nil for the default
+//
+func (id *HTTP) transport() *http.Transport {
+	proxying := DetectProxy()
+	timeouts := id.timeouts()
+	if !proxying && id.TLS == nil && timeouts.Connect <= 0 && timeouts.TLS <= 0 && timeouts.Header <= 0 {
+		return nil
+	}
+
+	var tlsConfig *tls.Config
+	if id.TLS != nil {
+		tlsConfig = id.TLS.Config()
+	} else {
+		tlsConfig = &tls.Config{}
+	}
+
+	transport := &http.Transport{
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   timeouts.TLS,
+		ResponseHeaderTimeout: timeouts.Header,
+	}
+	dialer := &net.Dialer{Timeout: timeouts.Connect, KeepAlive: HTTP_DEFAULT_TIMEOUT}
+	if timeouts.Connect > 0 {
+		transport.DialContext = dialer.DialContext
+	}
+
+	// if we're proxying, we're going to disable the TLS cert verification
+	if proxying {
+		id.ProxyURL, _ = url.Parse("http://127.0.0.1:8080")
+		transport.Proxy = http.ProxyURL(id.ProxyURL)
+		tlsConfig.InsecureSkipVerify = true
+	} else if id.TLS != nil && len(id.TLS.Pins) > 0 {
+		// pins are checked against the dialed host, which the shared config does not know
+		transport.DialTLSContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			return id._dialPinnedTLS(ctx, dialer, timeouts.TLS, network, addr)
+		}
+	}
+
+	return transport
+}
+
+//
+// Transport: Dial and handshake with the pins of the dialed host
+//
+func (id *HTTP) _dialPinnedTLS(ctx context.Context, dialer *net.Dialer, timeout time.Duration, network string, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	config := id.TLS.ConfigForHost(host)
+	if len(config.ServerName) == 0 {
+		config.ServerName = host
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err = tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
 //
 // Handler: redirections
 //
 func (id *HTTP) handleRedirection() string {
 	var result string
 
+	// each hop re-enters prepareAndExecuteRequest, the depth counts them
+	if id.redirectDepth > HTTP_MAX_REDIRECTS {
+		LogError(ErrTooManyRedirects)
+		id.err = ErrTooManyRedirects
+		return result
+	}
+
 	switch id.Status() {
 	case 200:
 		// OK
-		if id.isHTML() {
+		if id.Method == HTTP_HEAD {
+			LogDebug("HEAD response, contents not inspected")
+		} else if id.isHTML() {
 			LogDebug("HTML detected")
 			h := NewHTML()
 			s := NewDOM()
@@ -298,11 +664,19 @@ func (id *HTTP) handleRedirection() string {
 			result = id.Contents()
 		}
 		break
-	case 302:
-		// MOVED
+	case 301, 302, 303:
+		// MOVED / FOUND / SEE OTHER: anything but GET and HEAD becomes a GET
+		url := id.Location()
+		if id.Method == HTTP_GET || id.Method == HTTP_HEAD {
+			result = id.DoContent(id.Method, url, CONTENT_TYPE_NONE, nil)
+		} else {
+			result = id.Get(url)
+		}
+		break
+	case 307, 308:
+		// TEMPORARY / PERMANENT REDIRECT: the method and body are preserved
 		url := id.Location()
-		id.Get(url)
-		result = id.handleRedirection()
+		result = id.DoContent(id.Method, url, id.reqContentType, bytes.NewBuffer(id.reqContent))
 		break
 	default:
 		LogWarn("Unhandled status")
@@ -368,6 +742,51 @@ func (id *HTTP) JSON() (result map[string]interface{}, err error) {
 	return
 }
 
+//
+// Contents: Decode the JSON contents into target, a pointer to any type
+//
+func (id *HTTP) DecodeJSON(target interface{}, options ...JSONDecodeOption) error {
+	return DecodeJSON(id.RawContents, target, options...)
+}
+
+//
+// Fetch: POST request with v marshalled as the JSON body
+//
+func (id *HTTP) PostJSON(urlString string, v interface{}) (result string, err error) {
+	return id.DoJSON(HTTP_POST, urlString, v)
+}
+
+//
+// Fetch: PUT request with v marshalled as the JSON body
+//
+func (id *HTTP) PutJSON(urlString string, v interface{}) (result string, err error) {
+	return id.DoJSON(HTTP_PUT, urlString, v)
+}
+
+//
+// Fetch: PATCH request with v marshalled as the JSON body
+//
+func (id *HTTP) PatchJSON(urlString string, v interface{}) (result string, err error) {
+	return id.DoJSON(HTTP_PATCH, urlString, v)
+}
+
+//
+// Fetch: Request with an arbitrary method and v marshalled as the JSON body
+//
+func (id *HTTP) DoJSON(method string, urlString string, v interface{}) (result string, err error) {
+	jsonBytes, err := json.Marshal(v)
+	if err != nil {
+		LogError(err)
+		id.err = err
+		return
+	}
+
+	result = id.DoContent(method, urlString, CONTENT_TYPE_JSON, bytes.NewBuffer(jsonBytes))
+	err = id.err
+
+	return
+}
+
 //
 // Header: Extract Content-Type
 //
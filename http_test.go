@@ -0,0 +1,143 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newEchoTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Content-Type", r.Header.Get("Content-Type"))
+		fmt.Fprintf(w, "%s %s", r.Method, body)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/echo", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/see-other", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/echo", http.StatusSeeOther)
+	})
+	mux.HandleFunc("/temporary", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/echo", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/permanent", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/echo", http.StatusPermanentRedirect)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusTemporaryRedirect)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestHTTPVerbs(t *testing.T) {
+	server := newEchoTestServer()
+	defer server.Close()
+
+	x := NewHTTP()
+	if r := x.PutString(server.URL+"/echo", CONTENT_TYPE_JSON, "{}"); r != "PUT {}" {
+		t.Errorf("PUT result [%s]", r)
+	}
+	if r := x.PatchData(server.URL+"/echo", CONTENT_TYPE_JSON, []byte("[]")); r != "PATCH []" {
+		t.Errorf("PATCH result [%s]", r)
+	}
+	if r := x.Delete(server.URL + "/echo"); r != "DELETE " {
+		t.Errorf("DELETE result [%s]", r)
+	}
+	if r := x.Head(server.URL + "/echo"); r != "" || x.Status() != 200 {
+		t.Errorf("HEAD result [%s] status %d", r, x.Status())
+	}
+}
+
+func TestHTTPRedirectMethod(t *testing.T) {
+	server := newEchoTestServer()
+	defer server.Close()
+
+	x := NewHTTP()
+	if r := x.PostString(server.URL+"/see-other", CONTENT_TYPE_FORM, "a=1"); r != "GET " {
+		t.Errorf("303 result [%s]", r)
+	}
+	if r := x.PostString(server.URL+"/temporary", CONTENT_TYPE_FORM, "a=1"); r != "POST a=1" {
+		t.Errorf("307 result [%s]", r)
+	}
+	if r := x.PutString(server.URL+"/permanent", CONTENT_TYPE_JSON, `{"a":1}`); r != `PUT {"a":1}` {
+		t.Errorf("308 result [%s]", r)
+	}
+	if x.GetResponseHeader("X-Content-Type") != CONTENT_TYPE_JSON {
+		t.Errorf("308 replay lost the content type [%s]", x.GetResponseHeader("X-Content-Type"))
+	}
+	if r := x.PatchString(server.URL+"/moved", CONTENT_TYPE_JSON, "{}"); r != "GET " {
+		t.Errorf("301 result [%s]", r)
+	}
+}
+
+func TestHTTPRedirectLoop(t *testing.T) {
+	server := newEchoTestServer()
+	defer server.Close()
+
+	x := NewHTTP()
+	x.PostString(server.URL+"/loop", CONTENT_TYPE_FORM, "a=1")
+	if x.LastError() != ErrTooManyRedirects || x.Status() != http.StatusTemporaryRedirect {
+		t.Errorf("expected the loop cut short, got %v status %d", x.LastError(), x.Status())
+	}
+}
+
+func TestHTTPRedirectHead(t *testing.T) {
+	server := newEchoTestServer()
+	defer server.Close()
+
+	x := NewHTTP()
+	for _, path := range []string{"/moved", "/see-other", "/permanent"} {
+		r := x.Head(server.URL + path)
+		if r != "" || x.Status() != 200 || x.GetResponseHeader("X-Method") != HTTP_HEAD {
+			t.Errorf("HEAD %s result [%s] status %d method %s", path, r, x.Status(), x.GetResponseHeader("X-Method"))
+		}
+	}
+}
+
+func TestHTTPDo(t *testing.T) {
+	server := newEchoTestServer()
+	defer server.Close()
+
+	x := NewHTTP()
+	if r := x.Options(server.URL + "/echo"); r != "OPTIONS " {
+		t.Errorf("OPTIONS result [%s]", r)
+	}
+	if r := x.Do("propfind", server.URL+"/echo", bytes.NewBufferString("<a/>")); r != "PROPFIND <a/>" {
+		t.Errorf("Do result [%s]", r)
+	}
+	if x.GetResponseHeader("X-Content-Type") != "" {
+		t.Errorf("Do sent a content type [%s]", x.GetResponseHeader("X-Content-Type"))
+	}
+	if r := x.DoContent(HTTP_POST, server.URL+"/echo", CONTENT_TYPE_JSON, bytes.NewBufferString("[]")); r != "POST []" {
+		t.Errorf("DoContent result [%s]", r)
+	}
+	if x.GetResponseHeader("X-Content-Type") != CONTENT_TYPE_JSON {
+		t.Errorf("DoContent content type [%s]", x.GetResponseHeader("X-Content-Type"))
+	}
+	if r := x.Do(HTTP_GET, server.URL+"/echo", nil); r != "GET " {
+		t.Errorf("Do without a body result [%s]", r)
+	}
+}
+
+func TestHTTPPostJSON(t *testing.T) {
+	server := newEchoTestServer()
+	defer server.Close()
+
+	x := NewHTTP()
+	_, err := x.PostJSON(server.URL+"/echo", []int{1, 2, 3})
+	if err != nil || x.Contents() != "POST [1,2,3]" {
+		t.Errorf("PostJSON result [%s] %v", x.Contents(), err)
+	}
+}
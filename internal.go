@@ -5,15 +5,15 @@ package goweb
 import (
 	"net"
 	"net/http"
-	"time"
 )
 
 //
 // GetClient takes an unused http.Request so we can support GAE
+// The timeout is a default, HTTP applies its Timeouts on top.
 //
 func GetClient(r *http.Request) (client *http.Client) {
 	client = &http.Client{
-		Timeout: time.Second * 30,
+		Timeout: HTTP_DEFAULT_TIMEOUT,
 	}
 
 	return
@@ -33,7 +33,7 @@ func (self *JScript) ParseRedirect(d *DOM) string {
 	if len(scripts) > 0 {
 		LogDebug("SCRIPTs found")
 
-		re, _ := regexp.Compile("document.location\\s?=\\s?['\"](.+)[\"'];")
+		re, _ := regexp.Compile("(?:document|window)\\.location(?:\\.href)?\\s?=\\s?['\"](.+?)[\"'];?")
 
 		for _, script := range scripts {
 			match := re.FindStringSubmatch(script.Text())
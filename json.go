@@ -5,10 +5,12 @@
 package goweb
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	. "golog"
+	"io"
 	"strconv"
 	"strings"
 )
@@ -22,6 +24,14 @@ const (
 	JSONUnknownType
 )
 
+// JSONDecodeOption strict mode flags for DecodeJSON
+type JSONDecodeOption int
+
+const (
+	JSON_DISALLOW_UNKNOWN_FIELDS JSONDecodeOption = iota
+	JSON_USE_NUMBER
+)
+
 type _JSONDelimiter []string
 
 var _JSONArrayDelimiter = []string{"[", "]"}
@@ -94,6 +104,31 @@ func FromJSON(jsonString string) (result JSONMapType, err error) {
 	return
 }
 
+//
+// DecodeJSON decodes jsonBytes into target, a pointer to any type (struct, slice, map)
+//
+func DecodeJSON(jsonBytes []byte, target interface{}, options ...JSONDecodeOption) (err error) {
+	decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+	for _, option := range options {
+		switch option {
+		case JSON_DISALLOW_UNKNOWN_FIELDS:
+			decoder.DisallowUnknownFields()
+		case JSON_USE_NUMBER:
+			decoder.UseNumber()
+		}
+	}
+
+	err = decoder.Decode(target)
+	if err == nil {
+		// anything but the end of input, a stray closing delimiter included, is trailing data
+		if _, tokenErr := decoder.Token(); tokenErr != io.EOF {
+			err = errors.New("goweb: unexpected data after JSON value")
+		}
+	}
+
+	return
+}
+
 //
 // ExtractJSON isolates and tidys JSON string while attepting to parse the contents into a JSONMap
 //
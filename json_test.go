@@ -5,6 +5,7 @@
 package goweb
 
 import (
+	"encoding/json"
 	. "golog"
 	"testing"
 )
@@ -48,3 +49,38 @@ func TestTidyValues(t *testing.T) {
 		t.Errorf("JSON array length %d vs expected %d [%s]", len(r), 9, r)
 	}
 }
+
+func TestDecodeJSON(t *testing.T) {
+	SetLogLevel(LOG_DEBUG)
+	type item struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	var items []item
+	err := DecodeJSON([]byte(`[{"id": 1, "name": "a"}, {"id": 2, "name": "b"}]`), &items)
+	if err != nil || len(items) != 2 || items[1].Name != "b" {
+		t.Errorf("Error %s [%v]", err, items)
+	}
+
+	var strict item
+	err = DecodeJSON([]byte(`{"id": 1, "extra": true}`), &strict, JSON_DISALLOW_UNKNOWN_FIELDS)
+	if err == nil {
+		t.Errorf("Unknown field not rejected [%v]", strict)
+	}
+
+	var numbers map[string]interface{}
+	err = DecodeJSON([]byte(`{"n": 12345678901234567890}`), &numbers, JSON_USE_NUMBER)
+	if err != nil || numbers["n"].(json.Number).String() != "12345678901234567890" {
+		t.Errorf("Error %s [%v]", err, numbers)
+	}
+
+	for _, trailing := range []string{`{"id": 1}}`, `[1]]`, `{"id": 1} {"id": 2}`, `{"id": 1} x`} {
+		if err = DecodeJSON([]byte(trailing), &numbers); err == nil {
+			t.Errorf("Trailing data not rejected [%s]", trailing)
+		}
+	}
+	if err = DecodeJSON([]byte("{\"id\": 1}\n"), &numbers); err != nil {
+		t.Errorf("Trailing whitespace rejected %s", err)
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	_cssURL = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^)'"\s]*))\s*\)`)
+	// schemes that do not reference a document or asset
+	_linkSkipSchemes = []string{"javascript:", "data:", "about:"}
+)
+
+//
+// Link def
+// A reference found in the document, resolved against the page URL and base.
+// Attr is the attribute it came from, style for CSS url() references.
+//
+type Link struct {
+	URL      string
+	Href     string
+	Tag      string
+	Attr     string
+	Text     string
+	Rel      []string
+	External bool
+	Node     *DOMNode
+}
+
+//
+// HasRel : Does the rel attribute hold value?
+//
+func (id *Link) HasRel(value string) bool {
+	for _, rel := range id.Rel {
+		if rel == strings.ToLower(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//
+// NoFollow : Is the link marked not to be followed, nofollow, ugc or sponsored?
+//
+func (id *Link) NoFollow() bool {
+	return id.HasRel("nofollow") || id.HasRel("ugc") || id.HasRel("sponsored")
+}
+
+//
+// Links : The hyperlinks of the document, a, area and form actions, in document order
+// A nil pageURL falls back to the URL of the dump header, if any. Links to the
+// page host, with or without www, are internal.
+//
+func (id *DOM) Links(pageURL *url.URL) (result []*Link) {
+	return id._collectLinks(pageURL, false)
+}
+
+//
+// Resources : The assets of the document in document order
+// Stylesheets and other link elements, images and their srcset, scripts,
+// iframes, media sources and CSS url() references in style attributes and
+// style elements.
+//
+func (id *DOM) Resources(pageURL *url.URL) (result []*Link) {
+	return id._collectLinks(pageURL, true)
+}
+
+//
+// DOM: Walk the document collecting either the hyperlinks or the resources
+//
+func (id *DOM) _collectLinks(pageURL *url.URL, resources bool) (result []*Link) {
+	if pageURL == nil {
+		if header, err := id.DumpHeader(); err == nil {
+			pageURL, _ = url.Parse(header.URL)
+		}
+	}
+	if pageURL == nil {
+		pageURL = &url.URL{}
+	}
+	baseURL := id._baseURL(pageURL)
+
+	add := func(node *DOMNode, attr string, href string, text string) {
+		href = strings.TrimSpace(href)
+		if len(href) == 0 || (!resources && strings.HasPrefix(href, "#")) {
+			return
+		}
+		for _, scheme := range _linkSkipSchemes {
+			if strings.HasPrefix(strings.ToLower(href), scheme) {
+				return
+			}
+		}
+
+		link := &Link{Href: href, Tag: node.Tag, Attr: attr, Text: text, Node: node}
+		link.URL = href
+		if resolved, err := baseURL.Parse(href); err == nil {
+			link.URL = resolved.String()
+			link.External = _linkExternal(pageURL, resolved)
+		}
+		link.Rel = strings.Fields(strings.ToLower(node.Attr("rel")))
+		result = append(result, link)
+	}
+
+	for _, node := range id.document {
+		if !node.IsElement() {
+			continue
+		}
+
+		if !resources {
+			switch node.Tag {
+			case "a", "area":
+				add(node, "href", node.Attr("href"), strings.Join(strings.Fields(node.ReaderText()), " "))
+			case "form":
+				if action, ok := node.Attributes["action"]; ok {
+					add(node, "action", action, "")
+				}
+			}
+			continue
+		}
+
+		switch node.Tag {
+		case "link":
+			add(node, "href", node.Attr("href"), "")
+		case "img", "source":
+			add(node, "src", node.Attr("src"), node.Attr("alt"))
+			for _, candidate := range _srcsetURLs(node.Attr("srcset")) {
+				add(node, "srcset", candidate, node.Attr("alt"))
+			}
+		case "script", "iframe", "video", "audio", "embed", "track":
+			add(node, "src", node.Attr("src"), "")
+		case "style":
+			for _, href := range _cssURLs(node.RawText()) {
+				add(node, "style", href, "")
+			}
+		}
+		if node.Tag == "video" {
+			add(node, "poster", node.Attr("poster"), "")
+		}
+		if style := node.Attr("style"); len(style) > 0 {
+			for _, href := range _cssURLs(style) {
+				add(node, "style", href, "")
+			}
+		}
+	}
+
+	return result
+}
+
+//
+// DOM: The page URL as changed by the first base element
+//
+func (id *DOM) _baseURL(pageURL *url.URL) *url.URL {
+	if bases := id.Find("base", nil); len(bases) > 0 {
+		if href := strings.TrimSpace(bases[0].Attr("href")); len(href) > 0 {
+			if parsed, err := pageURL.Parse(href); err == nil {
+				return parsed
+			}
+		}
+	}
+
+	return pageURL
+}
+
+//
+// Links: Is target on another host than the page, or not a web URL at all?
+//
+func _linkExternal(pageURL *url.URL, target *url.URL) bool {
+	if len(target.Host) == 0 {
+		// still relative, for lack of a page URL, or mailto and the like
+		return len(target.Scheme) > 0 && target.Scheme != pageURL.Scheme
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return true
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(pageURL.Hostname()), "www.")
+	return strings.TrimPrefix(strings.ToLower(target.Hostname()), "www.") != host
+}
+
+//
+// Links: The candidate URLs of a srcset, without their width or density
+// As in the HTML parsing rules, a URL runs to whitespace and may hold commas,
+// its descriptors run to the next comma outside of parentheses.
+//
+func _srcsetURLs(srcset string) (result []string) {
+	const space = " \t\n\r\f"
+	for position := 0; position < len(srcset); {
+		rest := strings.TrimLeft(srcset[position:], space+",")
+		position = len(srcset) - len(rest)
+
+		end := strings.IndexAny(rest, space)
+		if end < 0 {
+			end = len(rest)
+		}
+		candidate := rest[:end]
+		position += end
+
+		// trailing commas end the candidate, which then has no descriptors
+		if trimmed := strings.TrimRight(candidate, ","); len(trimmed) < len(candidate) {
+			candidate = trimmed
+		} else {
+			for depth := 0; position < len(srcset) && (depth > 0 || srcset[position] != ','); position++ {
+				switch srcset[position] {
+				case '(':
+					depth++
+				case ')':
+					if depth > 0 {
+						depth--
+					}
+				}
+			}
+		}
+
+		if len(candidate) > 0 {
+			result = append(result, candidate)
+		}
+	}
+
+	return result
+}
+
+//
+// Links: The url() references of a style sheet or declaration
+//
+func _cssURLs(css string) (result []string) {
+	for _, match := range _cssURL.FindAllStringSubmatch(css, -1) {
+		// url(#id) points into the same document, such as an SVG gradient
+		if reference := strings.TrimSpace(match[1] + match[2] + match[3]); !strings.HasPrefix(reference, "#") {
+			result = append(result, reference)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,117 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+const _linksTestPage = `<html><head>
+<base href="/docs/">
+<link rel="stylesheet" href="site.css">
+<style>body { background: url("/img/bg.png") }</style>
+<script src="https://cdn.example.net/app.js"></script>
+</head><body>
+<a href="intro.html#top">Intro</a>
+<a href="https://www.example.com/about">About</a>
+<a href="https://other.org/" rel="nofollow noopener">Elsewhere</a>
+<a href="#section">Skip</a>
+<a href="javascript:void(0)">Nothing</a>
+<a href="mailto:team@example.com">Mail</a>
+<form action="/search"></form>
+<img src="a.png" srcset="a-2x.png 2x, a-3x.png 3x" alt="A">
+<div style="background-image: url(hero.jpg)"></div>
+<iframe src="//video.example.org/embed"></iframe>
+<picture><source srcset="b.webp"></picture>
+</body></html>`
+
+func TestLinks(t *testing.T) {
+	d := NewDOM()
+	d.SetContents(_linksTestPage)
+	pageURL, _ := url.Parse("https://example.com/guide/page.html")
+
+	links := d.Links(pageURL)
+	expected := []struct {
+		url      string
+		external bool
+	}{
+		{"https://example.com/docs/intro.html#top", false},
+		{"https://www.example.com/about", false},
+		{"https://other.org/", true},
+		{"mailto:team@example.com", true},
+		{"https://example.com/search", false},
+	}
+	if len(links) != len(expected) {
+		t.Fatalf("expected %d links, got %d", len(expected), len(links))
+	}
+	for i, link := range links {
+		if link.URL != expected[i].url || link.External != expected[i].external {
+			t.Errorf("unexpected link %d %s %v", i, link.URL, link.External)
+		}
+	}
+	if links[0].Text != "Intro" || links[4].Attr != "action" {
+		t.Errorf("unexpected link details %+v %+v", links[0], links[4])
+	}
+	if !links[2].NoFollow() || !links[2].HasRel("noopener") || links[1].NoFollow() {
+		t.Errorf("unexpected rel %q", links[2].Rel)
+	}
+}
+
+func TestResources(t *testing.T) {
+	d := NewDOM()
+	d.SetContents(_linksTestPage)
+	pageURL, _ := url.Parse("https://example.com/guide/page.html")
+
+	expected := []string{
+		"https://example.com/docs/site.css",
+		"https://example.com/img/bg.png",
+		"https://cdn.example.net/app.js",
+		"https://example.com/docs/a.png",
+		"https://example.com/docs/a-2x.png",
+		"https://example.com/docs/a-3x.png",
+		"https://example.com/docs/hero.jpg",
+		"https://video.example.org/embed",
+		"https://example.com/docs/b.webp",
+	}
+	resources := d.Resources(pageURL)
+	if len(resources) != len(expected) {
+		t.Fatalf("expected %d resources, got %d", len(expected), len(resources))
+	}
+	for i, resource := range resources {
+		if resource.URL != expected[i] {
+			t.Errorf("expected %s, got %s", expected[i], resource.URL)
+		}
+	}
+	if !resources[2].External || resources[0].External || resources[4].Attr != "srcset" || resources[6].Attr != "style" {
+		t.Errorf("unexpected resource details %+v", resources)
+	}
+
+	// without a page URL the dump header supplies it
+	d.SetContents(`<!--
+Method: GET
+URL: https://example.com/a/b.html
+Status: 200
+-->
+<html><body><a href="c.html">C</a></body></html>`)
+	if links := d.Links(nil); len(links) != 1 || links[0].URL != "https://example.com/a/c.html" {
+		t.Errorf("unexpected links %+v", links)
+	}
+}
+
+func TestResourceURLParsing(t *testing.T) {
+	// srcset URLs may hold commas, descriptors end at the next comma
+	srcset := _srcsetURLs(" https://res.cloudinary.com/demo/image/upload/w_100,h_100/a.jpg 1x,b.jpg 2x , c.jpg,, d(1).jpg 100w ")
+	expected := []string{"https://res.cloudinary.com/demo/image/upload/w_100,h_100/a.jpg", "b.jpg", "c.jpg", "d(1).jpg"}
+	if !reflect.DeepEqual(srcset, expected) {
+		t.Errorf("expected %q, got %q", expected, srcset)
+	}
+
+	css := _cssURLs(`.a { fill: url(#grad); background: url( "bg.png" ) } .b { mask: url('#m') }`)
+	if !reflect.DeepEqual(css, []string{"bg.png"}) {
+		t.Errorf("expected only bg.png, got %q", css)
+	}
+}
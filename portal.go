@@ -0,0 +1,354 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"errors"
+	"fmt"
+	. "golog"
+	"math/rand"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ErrPortalLoginFailed is returned when no strategy established connectivity
+var ErrPortalLoginFailed = errors.New("goweb: captive portal login failed")
+
+const (
+	PORTAL_REASON_NONE        = ""
+	PORTAL_REASON_REDIRECT    = "redirect"
+	PORTAL_REASON_BODY        = "body"
+	PORTAL_REASON_DNS         = "dns"
+	PORTAL_REASON_UNREACHABLE = "unreachable"
+)
+
+//
+// PortalProbe def
+// A connectivity check URL and the response expected without interception,
+// an empty Body matches any body.
+//
+type PortalProbe struct {
+	URL    string
+	Status int
+	Body   string
+}
+
+// PORTAL_PROBES are the well-known OS connectivity checks
+var PORTAL_PROBES = []PortalProbe{
+	{URL: "http://connectivitycheck.gstatic.com/generate_204", Status: 204},
+	{URL: "http://captive.apple.com/hotspot-detect.html", Status: 200, Body: "Success"},
+	{URL: "http://www.msftconnecttest.com/connecttest.txt", Status: 200, Body: "Microsoft Connect Test"},
+}
+
+//
+// PortalState def
+//
+type PortalState struct {
+	Connected   bool
+	Intercepted bool
+	Reason      string
+	Probe       string
+	PortalURL   string
+}
+
+//
+// PortalPage def
+//
+type PortalPage struct {
+	URL      *url.URL
+	Contents string
+	DOM      *DOM
+}
+
+//
+// PortalStrategy is a pluggable login attempt against a portal page
+// Login returns true when it submitted something worth re-probing for.
+//
+type PortalStrategy interface {
+	Name() string
+	Login(h *HTTP, page *PortalPage) (bool, error)
+}
+
+//
+// CaptivePortal def
+//
+type CaptivePortal struct {
+	HTTP        *HTTP
+	Probes      []PortalProbe
+	Strategies  []PortalStrategy
+	MaxAttempts int
+	// DNSCheckHost should never resolve, empty picks a random example.com label
+	DNSCheckHost string
+	LookupHost   func(host string) ([]string, error)
+}
+
+//
+// NewCaptivePortal constructor
+//
+func NewCaptivePortal() *CaptivePortal {
+	return &CaptivePortal{
+		HTTP:        NewHTTP(),
+		Probes:      PORTAL_PROBES,
+		Strategies:  []PortalStrategy{NewAcceptTermsStrategy()},
+		MaxAttempts: 3,
+		LookupHost:  net.LookupHost,
+	}
+}
+
+//
+// Detect : Probe for connectivity and classify any interception
+// A passing probe wins over a hijacking resolver, which is only reported
+// when no probe could establish either connectivity or interception.
+//
+func (id *CaptivePortal) Detect() (state PortalState) {
+	for _, probe := range id.Probes {
+		probeState, ok := id.probe(probe)
+		if ok && (probeState.Connected || probeState.Intercepted) {
+			return probeState
+		}
+	}
+
+	if id.dnsHijacked() {
+		LogDebug("Portal DNS hijack detected")
+		state.Intercepted = true
+		state.Reason = PORTAL_REASON_DNS
+	} else {
+		state.Reason = PORTAL_REASON_UNREACHABLE
+	}
+
+	return state
+}
+
+//
+// CaptivePortal: A resolvable bogus host means the resolver answers everything
+//
+func (id *CaptivePortal) dnsHijacked() bool {
+	if id.LookupHost == nil {
+		return false
+	}
+
+	host := id.DNSCheckHost
+	if len(host) == 0 {
+		host = fmt.Sprintf("goweb-%x.example.com", rand.Int63())
+	}
+
+	addrs, err := id.LookupHost(host)
+
+	return err == nil && len(addrs) > 0
+}
+
+//
+// CaptivePortal: Fetch a probe without following redirects, ok is false if unreachable
+//
+func (id *CaptivePortal) probe(probe PortalProbe) (state PortalState, ok bool) {
+	// the HTTP belongs to the caller, its settings are restored afterwards
+	h := id.HTTP
+	disableRedirects, previousURL := h.DisableRedirects, h.URL
+	h.DisableRedirects = true
+	defer func() {
+		h.DisableRedirects = disableRedirects
+		h.URL = previousURL
+	}()
+
+	h.URL = nil
+	contents := h.Get(probe.URL)
+	if h.LastError() != nil {
+		LogDebug("Portal probe unreachable: " + probe.URL)
+		return state, false
+	}
+
+	state.Probe = probe.URL
+	status := h.Status()
+
+	switch {
+	case status >= 300 && status < 400:
+		state.Intercepted = true
+		state.Reason = PORTAL_REASON_REDIRECT
+		state.PortalURL = h.Location()
+		if location, err := h.URL.Parse(state.PortalURL); err == nil {
+			state.PortalURL = location.String()
+		}
+	case status == probe.Status && (len(probe.Body) == 0 || strings.Contains(contents, probe.Body)):
+		state.Connected = true
+	default:
+		// the portal answered in place of the probe
+		state.Intercepted = true
+		state.Reason = PORTAL_REASON_BODY
+		state.PortalURL = probe.URL
+		if h.isHTML() {
+			d := NewDOM()
+			d.SetContents(contents)
+			if redirect := NewHTML().ParseRedirect(d); len(redirect) > 0 {
+				if location, err := h.URL.Parse(redirect); err == nil {
+					state.PortalURL = location.String()
+				}
+			}
+		}
+	}
+
+	return state, true
+}
+
+//
+// Login : Run the strategies against the portal until connectivity is confirmed
+//
+func (id *CaptivePortal) Login() (state PortalState, err error) {
+	for attempt := 0; attempt < id.MaxAttempts; attempt++ {
+		state = id.Detect()
+		if state.Connected {
+			return state, nil
+		}
+		if !state.Intercepted || len(state.PortalURL) == 0 {
+			return state, ErrPortalLoginFailed
+		}
+
+		// meta and script redirects are followed to the actual login page
+		id.HTTP.URL = nil
+		contents := id.HTTP.Get(state.PortalURL)
+		if err = id.HTTP.LastError(); err != nil {
+			return state, err
+		}
+
+		page := &PortalPage{URL: id.HTTP.URL, Contents: contents, DOM: NewDOM()}
+		page.DOM.SetContents(contents)
+
+		for _, strategy := range id.Strategies {
+			submitted, strategyErr := strategy.Login(id.HTTP, page)
+			if strategyErr != nil {
+				LogWarn("Portal strategy " + strategy.Name() + ": " + strategyErr.Error())
+				continue
+			}
+			if submitted {
+				LogDebug("Portal strategy submitted: " + strategy.Name())
+				break
+			}
+		}
+	}
+
+	state = id.Detect()
+	if !state.Connected {
+		err = ErrPortalLoginFailed
+	}
+
+	return state, err
+}
+
+//
+// AcceptTermsStrategy def
+// Submits the first form without a password field, checking every checkbox.
+//
+type AcceptTermsStrategy struct {
+}
+
+//
+// NewAcceptTermsStrategy constructor
+//
+func NewAcceptTermsStrategy() *AcceptTermsStrategy {
+	return &AcceptTermsStrategy{}
+}
+
+func (id *AcceptTermsStrategy) Name() string {
+	return "accept-terms"
+}
+
+func (id *AcceptTermsStrategy) Login(h *HTTP, page *PortalPage) (bool, error) {
+	for _, form := range NewHTML().ParseForms(page.DOM, page.URL) {
+		if len(form.Fields("password")) > 0 {
+			continue
+		}
+
+		for _, checkbox := range _formCheckboxes(page.DOM, form) {
+			form.Values.Set(checkbox.Attr("name"), _checkboxValue(checkbox))
+		}
+
+		h.SubmitForm(form)
+		return true, h.LastError()
+	}
+
+	return false, nil
+}
+
+//
+// CredentialStrategy def
+// Fills the username and password fields of the first form with a password field.
+//
+type CredentialStrategy struct {
+	Username string
+	Password string
+}
+
+//
+// NewCredentialStrategy constructor
+//
+func NewCredentialStrategy(username string, password string) *CredentialStrategy {
+	return &CredentialStrategy{Username: username, Password: password}
+}
+
+func (id *CredentialStrategy) Name() string {
+	return "credentials"
+}
+
+func (id *CredentialStrategy) Login(h *HTTP, page *PortalPage) (bool, error) {
+	for _, form := range NewHTML().ParseForms(page.DOM, page.URL) {
+		passwords := form.Fields("password")
+		if len(passwords) == 0 {
+			continue
+		}
+
+		for _, name := range passwords {
+			form.Values.Set(name, id.Password)
+		}
+		if user := _formUserField(page.DOM, form); len(user) > 0 {
+			form.Values.Set(user, id.Username)
+		}
+		for _, checkbox := range _formCheckboxes(page.DOM, form) {
+			form.Values.Set(checkbox.Attr("name"), _checkboxValue(checkbox))
+		}
+
+		h.SubmitForm(form)
+		return true, h.LastError()
+	}
+
+	return false, nil
+}
+
+//
+// Portal: The first text or email input of the form in document order
+//
+func _formUserField(d *DOM, form *HTMLForm) string {
+	for _, input := range d.ChildFind(form.Node, "input", nil) {
+		name := input.Attr("name")
+		if fieldType := form.Types[name]; len(name) > 0 && (fieldType == "text" || fieldType == "email") {
+			return name
+		}
+	}
+
+	return ""
+}
+
+//
+// Portal: The named checkbox inputs of the form
+//
+func _formCheckboxes(d *DOM, form *HTMLForm) (result []*DOMNode) {
+	for _, input := range d.ChildFind(form.Node, "input", DOMNodeAttributes{"type": "checkbox"}) {
+		if len(input.Attr("name")) > 0 {
+			result = append(result, input)
+		}
+	}
+
+	return result
+}
+
+//
+// Portal: The submitted value of a checked checkbox
+//
+func _checkboxValue(checkbox *DOMNode) string {
+	if value := checkbox.Attr("value"); len(value) > 0 {
+		return value
+	}
+
+	return "on"
+}
@@ -0,0 +1,145 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// a stand-in portal intercepting the probe until the terms are accepted
+func newPortalTestServer() *httptest.Server {
+	var lock sync.Mutex
+	accepted := false
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/generate_204", func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		if !accepted {
+			http.Redirect(w, r, "/welcome", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/welcome", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><meta http-equiv="refresh" content="0; url=http://`+r.Host+`/terms"></head></html>`)
+	})
+	mux.HandleFunc("/terms", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><form action="/accept" method="post">
+			<input type="hidden" name="cmd" value="authenticate">
+			<input type="checkbox" name="terms" value="yes">
+			<input type="submit" value="Connect">
+		</form></body></html>`)
+	})
+	mux.HandleFunc("/accept", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		lock.Lock()
+		accepted = r.Form.Get("terms") == "yes" && r.Form.Get("cmd") == "authenticate"
+		lock.Unlock()
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "welcome")
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func newTestCaptivePortal(server *httptest.Server) *CaptivePortal {
+	portal := NewCaptivePortal()
+	portal.Probes = []PortalProbe{{URL: server.URL + "/generate_204", Status: 204}}
+	portal.LookupHost = func(host string) ([]string, error) {
+		return nil, errors.New("no such host")
+	}
+
+	return portal
+}
+
+func TestCaptivePortalDetect(t *testing.T) {
+	server := newPortalTestServer()
+	defer server.Close()
+
+	portal := newTestCaptivePortal(server)
+	portal.HTTP.DisableRedirects = true
+	previousURL, _ := url.Parse(server.URL + "/previous")
+	portal.HTTP.URL = previousURL
+
+	state := portal.Detect()
+	if !state.Intercepted || state.Reason != PORTAL_REASON_REDIRECT || state.PortalURL != server.URL+"/welcome" {
+		t.Errorf("Portal state %+v", state)
+	}
+	// the probes leave the caller's settings as they were
+	if !portal.HTTP.DisableRedirects || portal.HTTP.URL != previousURL {
+		t.Errorf("Portal probe altered the HTTP %v %v", portal.HTTP.DisableRedirects, portal.HTTP.URL)
+	}
+}
+
+func TestCaptivePortalLogin(t *testing.T) {
+	server := newPortalTestServer()
+	defer server.Close()
+
+	state, err := newTestCaptivePortal(server).Login()
+	if err != nil || !state.Connected {
+		t.Errorf("Portal login %+v %v", state, err)
+	}
+}
+
+func TestCaptivePortalDNS(t *testing.T) {
+	portal := NewCaptivePortal()
+	portal.Probes = []PortalProbe{{URL: "http://127.0.0.1:1/generate_204", Status: 204}}
+	portal.LookupHost = func(host string) ([]string, error) {
+		return []string{"10.0.0.1"}, nil
+	}
+
+	state := portal.Detect()
+	if !state.Intercepted || state.Reason != PORTAL_REASON_DNS {
+		t.Errorf("Portal state %+v", state)
+	}
+}
+
+func TestParseForms(t *testing.T) {
+	contents := loadData(t, "test_b.html")
+
+	d := NewDOM()
+	d.SetContents(contents)
+
+	forms := NewHTML().ParseForms(d, nil)
+	if len(forms) != 1 || forms[0].Method != HTTP_POST || forms[0].Values.Get("cmd") != "authenticate" {
+		t.Errorf("Forms %v", forms)
+	}
+}
+
+func TestCredentialStrategy(t *testing.T) {
+	submitted := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		submitted <- r.Form.Encode()
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+
+	// the user field comes first in the page, not first by name or type
+	page := &PortalPage{DOM: NewDOM()}
+	page.DOM.SetContents(`<html><body><form action="` + server.URL + `/login" method="post">
+		<input type="text" name="zz_user">
+		<input type="email" name="email">
+		<input type="password" name="pass">
+	</form></body></html>`)
+
+	strategy := &CredentialStrategy{Username: "alice", Password: "secret"}
+	ok, err := strategy.Login(NewHTTP(), page)
+	if !ok || err != nil {
+		t.Fatalf("Credential login %t %v", ok, err)
+	}
+	if form := <-submitted; form != "email=&pass=secret&zz_user=alice" {
+		t.Errorf("Submitted form [%s]", form)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+//
+// RateLimitMetrics def
+//
+type RateLimitMetrics struct {
+	Requests int
+	Waits    int
+	WaitTime time.Duration
+}
+
+//
+// host bucket and politeness state
+//
+type _hostLimit struct {
+	tokens     float64
+	refilled   time.Time
+	nextStart  time.Time
+	crawlDelay time.Duration
+	slots      chan struct{}
+	metrics    RateLimitMetrics
+}
+
+//
+// RateLimiter def
+// A token bucket of Rate requests per second (Burst deep) per host, with
+// at most MaxConcurrency in flight requests per host and a randomized
+// MinDelay to MaxDelay politeness gap between request starts.
+//
+type RateLimiter struct {
+	Rate           float64
+	Burst          int
+	MaxConcurrency int
+	MinDelay       time.Duration
+	MaxDelay       time.Duration
+	hosts          map[string]*_hostLimit
+	lock           sync.Mutex
+}
+
+//
+// NewRateLimiter constructor, rate <= 0 disables the token bucket
+//
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &RateLimiter{Rate: rate, Burst: burst, hosts: make(map[string]*_hostLimit)}
+}
+
+//
+// RateLimiter: Fetch or create the host state, the caller holds the lock
+//
+func (id *RateLimiter) host(host string) *_hostLimit {
+	host = strings.ToLower(host)
+	limit := id.hosts[host]
+	if limit == nil {
+		limit = &_hostLimit{tokens: float64(id.Burst), refilled: time.Now()}
+		if id.MaxConcurrency > 0 {
+			limit.slots = make(chan struct{}, id.MaxConcurrency)
+		}
+		id.hosts[host] = limit
+	}
+
+	return limit
+}
+
+//
+// SetCrawlDelay : The minimum gap between request starts for host (robots.txt Crawl-delay)
+//
+func (id *RateLimiter) SetCrawlDelay(host string, delay time.Duration) {
+	id.lock.Lock()
+	defer id.lock.Unlock()
+
+	id.host(host).crawlDelay = delay
+}
+
+//
+// Acquire : Block until a request to host is permitted, returns the time spent waiting
+//
+func (id *RateLimiter) Acquire(host string) (waited time.Duration) {
+	start := time.Now()
+
+	id.lock.Lock()
+	limit := id.host(host)
+	id.lock.Unlock()
+
+	// concurrency first, so queued requests don't consume tokens early
+	if limit.slots != nil {
+		limit.slots <- struct{}{}
+	}
+
+	id.lock.Lock()
+	now := time.Now()
+	delay := time.Duration(0)
+
+	if id.Rate > 0 {
+		limit.tokens += now.Sub(limit.refilled).Seconds() * id.Rate
+		if limit.tokens > float64(id.Burst) {
+			limit.tokens = float64(id.Burst)
+		}
+		limit.refilled = now
+		// a negative balance reserves a future token
+		limit.tokens -= 1
+		if limit.tokens < 0 {
+			delay = time.Duration(-limit.tokens / id.Rate * float64(time.Second))
+		}
+	}
+
+	gap := limit.crawlDelay + id.jitter()
+	if gap > 0 && !limit.nextStart.IsZero() {
+		if politeDelay := limit.nextStart.Add(gap).Sub(now); politeDelay > delay {
+			delay = politeDelay
+		}
+	}
+	limit.nextStart = now.Add(delay)
+	id.lock.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	waited = time.Since(start)
+
+	id.lock.Lock()
+	limit.metrics.Requests += 1
+	if delay > 0 || waited > time.Millisecond {
+		limit.metrics.Waits += 1
+	}
+	limit.metrics.WaitTime += waited
+	id.lock.Unlock()
+
+	return waited
+}
+
+//
+// Release : Return the concurrency slot taken by Acquire
+//
+func (id *RateLimiter) Release(host string) {
+	id.lock.Lock()
+	limit := id.host(host)
+	id.lock.Unlock()
+
+	if limit.slots != nil {
+		<-limit.slots
+	}
+}
+
+//
+// RateLimiter: Randomized politeness delay between MinDelay and MaxDelay
+//
+func (id *RateLimiter) jitter() time.Duration {
+	if id.MaxDelay <= id.MinDelay {
+		return id.MinDelay
+	}
+
+	return id.MinDelay + time.Duration(rand.Int63n(int64(id.MaxDelay-id.MinDelay)))
+}
+
+//
+// Metrics : The wait metrics for host
+//
+func (id *RateLimiter) Metrics(host string) RateLimitMetrics {
+	id.lock.Lock()
+	defer id.lock.Unlock()
+
+	return id.host(host).metrics
+}
+
+//
+// TotalMetrics : The wait metrics summed across all hosts
+//
+func (id *RateLimiter) TotalMetrics() (result RateLimitMetrics) {
+	id.lock.Lock()
+	defer id.lock.Unlock()
+
+	for _, limit := range id.hosts {
+		result.Requests += limit.metrics.Requests
+		result.Waits += limit.metrics.Waits
+		result.WaitTime += limit.metrics.WaitTime
+	}
+
+	return result
+}
@@ -0,0 +1,63 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBucket(t *testing.T) {
+	limiter := NewRateLimiter(20, 1)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		limiter.Acquire("example.com")
+		limiter.Release("example.com")
+	}
+
+	// the first request spends the burst token, the next two wait 50ms each
+	elapsed := time.Since(start)
+	if elapsed < 90*time.Millisecond {
+		t.Errorf("RateLimiter elapsed %s vs expected >= %s", elapsed, 90*time.Millisecond)
+	}
+
+	metrics := limiter.Metrics("example.com")
+	if metrics.Requests != 3 || metrics.Waits != 2 {
+		t.Errorf("RateLimiter metrics %+v", metrics)
+	}
+}
+
+func TestRateLimiterConcurrency(t *testing.T) {
+	limiter := NewRateLimiter(0, 1)
+	limiter.MaxConcurrency = 2
+
+	var lock sync.Mutex
+	active, peak := 0, 0
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limiter.Acquire("example.com")
+			lock.Lock()
+			active += 1
+			if active > peak {
+				peak = active
+			}
+			lock.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			lock.Lock()
+			active -= 1
+			lock.Unlock()
+			limiter.Release("example.com")
+		}()
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Errorf("RateLimiter concurrency %d vs expected %d", peak, 2)
+	}
+}
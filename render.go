@@ -0,0 +1,167 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"html"
+	"sort"
+	"strings"
+)
+
+//
+// RenderOption def
+//
+type RenderOption int
+
+const (
+	// RENDER_PRETTY indents nested elements, one per line
+	RENDER_PRETTY RenderOption = iota + 1
+)
+
+// RENDER_INDENT is the indentation of one nesting level in pretty mode
+const RENDER_INDENT = "  "
+
+var (
+	// elements without content or an end tag
+	_voidTags = map[string]bool{
+		"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+		"input": true, "keygen": true, "link": true, "meta": true, "param": true, "source": true,
+		"track": true, "wbr": true,
+	}
+	// elements whose text is written verbatim
+	_rawTextTags = map[string]bool{
+		"script": true, "style": true, "template": true, "noscript": true, "xmp": true, "iframe": true,
+		"noembed": true, "noframes": true, "plaintext": true,
+	}
+	// elements whose whitespace is significant, never re-indented
+	_preformattedTags = map[string]bool{"pre": true, "textarea": true, "listing": true}
+)
+
+//
+// OuterHTML : The node serialized as HTML, including its own tags
+//
+func (id *DOMNode) OuterHTML(options ...RenderOption) string {
+	var out strings.Builder
+	id._render(&out, _renderPretty(options), 0)
+
+	return strings.TrimSpace(out.String())
+}
+
+//
+// InnerHTML : The content of the node serialized as HTML
+//
+func (id *DOMNode) InnerHTML(options ...RenderOption) string {
+	var out strings.Builder
+	id._renderContent(&out, _renderPretty(options), 0)
+
+	return strings.TrimSpace(out.String())
+}
+
+//
+// Render : The document serialized as HTML, doctype and top level comments included
+//
+func (id *DOM) Render(options ...RenderOption) string {
+	if document := id.Document(); document != nil {
+		return document.InnerHTML(options...)
+	}
+	if root := id.RootNode(); root != nil {
+		return root.OuterHTML(options...)
+	}
+
+	return ""
+}
+
+func _renderPretty(options []RenderOption) bool {
+	for _, option := range options {
+		if option == RENDER_PRETTY {
+			return true
+		}
+	}
+
+	return false
+}
+
+//
+// Render: Write the node, indenting block content when pretty
+//
+func (id *DOMNode) _render(out *strings.Builder, pretty bool, depth int) {
+	if pretty {
+		out.WriteString("\n" + strings.Repeat(RENDER_INDENT, depth))
+	}
+
+	switch id.Tag {
+	case DOM_COMMENT_TAG:
+		out.WriteString("<!--" + id.Data + "-->")
+		return
+	case DOM_DOCTYPE_TAG:
+		out.WriteString("<!DOCTYPE " + id.Data)
+		if public := id.Attr("public"); len(public) > 0 {
+			out.WriteString(" PUBLIC \"" + public + "\"")
+			if system := id.Attr("system"); len(system) > 0 {
+				out.WriteString(" \"" + system + "\"")
+			}
+		} else if system := id.Attr("system"); len(system) > 0 {
+			out.WriteString(" SYSTEM \"" + system + "\"")
+		}
+		out.WriteString(">")
+		return
+	case DOM_DOCUMENT_TAG, DOM_ERROR_TAG:
+		id._renderContent(out, pretty, depth)
+		return
+	}
+
+	out.WriteString("<" + id.Tag)
+	keys := make([]string, 0, len(id.Attributes))
+	for key := range id.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		out.WriteString(" " + key + "=\"" + html.EscapeString(id.Attributes[key]) + "\"")
+	}
+	out.WriteString(">")
+
+	if _voidTags[id.Tag] {
+		return
+	}
+
+	// element children go on their own lines, text only content stays inline
+	blockPretty := pretty && len(id.Children) > 0 && !_preformattedTags[id.Tag] && !_rawTextTags[id.Tag]
+	id._renderContent(out, blockPretty, depth+1)
+	if blockPretty {
+		out.WriteString("\n" + strings.Repeat(RENDER_INDENT, depth))
+	}
+
+	out.WriteString("</" + id.Tag + ">")
+}
+
+//
+// Render: Write the child nodes in document order
+// Pretty printing trims the text nodes and drops the whitespace only ones,
+// except in raw text and preformatted elements which are written as parsed.
+//
+func (id *DOMNode) _renderContent(out *strings.Builder, pretty bool, depth int) {
+	pretty = pretty && !_rawTextTags[id.Tag] && !_preformattedTags[id.Tag]
+	for _, node := range id.ChildNodes {
+		if !node.IsText() {
+			node._render(out, pretty, depth)
+			continue
+		}
+
+		text := node.Data
+		if pretty {
+			text = strings.TrimSpace(text)
+			if len(text) == 0 {
+				continue
+			}
+			out.WriteString("\n" + strings.Repeat(RENDER_INDENT, depth))
+		}
+		if _rawTextTags[id.Tag] {
+			out.WriteString(text)
+		} else {
+			out.WriteString(html.EscapeString(text))
+		}
+	}
+}
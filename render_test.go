@@ -0,0 +1,83 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	d := NewDOM()
+	d.SetContents(`<!DOCTYPE html><html><head><title>A &amp; B</title></head><body><div id="a" title='say "hi"'>x &lt; y<br><img src="a.png"></div><script>if (a < b) {}</script></body></html>`)
+
+	div := d.Find("div", DOMNodeAttributes{"id": "a"})[0]
+	expected := `<div id="a" title="say &#34;hi&#34;">x &lt; y<br><img src="a.png"></div>`
+	if div.OuterHTML() != expected {
+		t.Errorf("expected %s, got %s", expected, div.OuterHTML())
+	}
+	if div.InnerHTML() != `x &lt; y<br><img src="a.png">` {
+		t.Errorf("unexpected inner html %s", div.InnerHTML())
+	}
+
+	script := d.Find("script", nil)[0]
+	if script.OuterHTML() != "<script>if (a < b) {}</script>" {
+		t.Errorf("expected raw script text, got %s", script.OuterHTML())
+	}
+
+	expected = `<!DOCTYPE html>
+<html>
+  <head>
+    <title>A &amp; B</title>
+  </head>
+  <body>
+    <div id="a" title="say &#34;hi&#34;">
+      x &lt; y
+      <br>
+      <img src="a.png">
+    </div>
+    <script>if (a < b) {}</script>
+  </body>
+</html>`
+	if d.Render(RENDER_PRETTY) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, d.Render(RENDER_PRETTY))
+	}
+
+	// the rendered document parses back to the same tree
+	reparsed := NewDOM()
+	reparsed.SetContents(d.Render())
+	if reparsed.Render() != d.Render() {
+		t.Errorf("expected a stable round trip, got %s", reparsed.Render())
+	}
+}
+
+func TestRenderEscapedMarkup(t *testing.T) {
+	d := NewDOM()
+	d.SetContents(`<html><head></head><body><p id="x">a &lt;b&gt; c &amp; d</p></body></html>`)
+
+	expected := `<p id="x">a &lt;b&gt; c &amp; d</p>`
+	if p := d.Find("p", nil)[0]; p.OuterHTML() != expected {
+		t.Errorf("expected %s, got %s", expected, p.OuterHTML())
+	}
+
+	reparsed := NewDOM()
+	reparsed.SetContents(d.Render())
+	if reparsed.Render() != d.Render() || len(reparsed.Find("b", nil)) != 0 {
+		t.Errorf("expected a stable round trip, got %s", reparsed.Render())
+	}
+}
+
+func TestRenderPrettyRawText(t *testing.T) {
+	d := NewDOM()
+	d.SetContents("<html><head></head><body><script>\n  var a = 1;\n\n  var b = 2;\n</script></body></html>")
+
+	script := d.Find("script", nil)[0]
+	expected := "<script>\n  var a = 1;\n\n  var b = 2;\n</script>"
+	if script.OuterHTML(RENDER_PRETTY) != expected {
+		t.Errorf("expected %q, got %q", expected, script.OuterHTML(RENDER_PRETTY))
+	}
+	if script.InnerHTML(RENDER_PRETTY) != script.InnerHTML() {
+		t.Errorf("expected the script content untouched, got %q", script.InnerHTML(RENDER_PRETTY))
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"bufio"
+	. "golog"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//
+// RobotsDisallowedError is returned when robots.txt forbids the request
+//
+type RobotsDisallowedError struct {
+	URL       string
+	UserAgent string
+}
+
+func (id *RobotsDisallowedError) Error() string {
+	return "goweb: robots.txt disallows " + id.URL + " for " + id.UserAgent
+}
+
+// the product tokens of a user agent string, as in Googlebot of "Mozilla/5.0 (compatible; Googlebot/2.1)"
+var _robotsProductToken = regexp.MustCompile(`(?:^|[\s(;])([A-Za-z_-]+)(?:/|$|[\s;)])`)
+
+//
+// robots.txt rule
+//
+type _robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+//
+// robots.txt user-agent group
+//
+type _robotsGroup struct {
+	agents     []string
+	rules      []_robotsRule
+	crawlDelay time.Duration
+}
+
+//
+// Robots def
+//
+type Robots struct {
+	Sitemaps    []string
+	groups      []*_robotsGroup
+	disallowAll bool
+}
+
+//
+// ParseRobots : Parse the robots.txt contents into user-agent groups
+//
+func ParseRobots(contents string) *Robots {
+	id := &Robots{}
+
+	var group *_robotsGroup
+	// consecutive user-agent lines share the group that follows
+	groupOpen := false
+
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx > -1 {
+			line = line[:idx]
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		value := strings.TrimSpace(parts[1])
+
+		switch key {
+		case "user-agent":
+			if !groupOpen {
+				group = &_robotsGroup{}
+				id.groups = append(id.groups, group)
+				groupOpen = true
+			}
+			group.agents = append(group.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			groupOpen = false
+			// an empty Disallow matches nothing
+			if group != nil && len(value) > 0 {
+				group.rules = append(group.rules, _robotsRule{allow: key == "allow", pattern: value})
+			}
+		case "crawl-delay":
+			groupOpen = false
+			if group != nil {
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+					group.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		case "sitemap":
+			// sitemap lines are independent of any group
+			if len(value) > 0 {
+				id.Sitemaps = append(id.Sitemaps, value)
+			}
+		}
+	}
+
+	return id
+}
+
+//
+// Robots: The rules and crawl delay of the groups matching the user agent
+// The group naming a product token of userAgent wins, the longest if several do, "*" otherwise.
+//
+func (id *Robots) match(userAgent string) (rules []_robotsRule, crawlDelay time.Duration) {
+	tokens := map[string]bool{}
+	for _, match := range _robotsProductToken.FindAllStringSubmatch(strings.ToLower(userAgent), -1) {
+		tokens[match[1]] = true
+	}
+
+	longest := -1
+	for _, group := range id.groups {
+		length := -1
+		for _, agent := range group.agents {
+			if agent == "*" && length < 0 {
+				length = 0
+			} else if agent != "*" && tokens[agent] && len(agent) > length {
+				length = len(agent)
+			}
+		}
+
+		if length > longest {
+			longest = length
+			rules = nil
+			crawlDelay = 0
+		}
+		if length == longest && length > -1 {
+			// groups for the same agent are combined
+			rules = append(rules, group.rules...)
+			if group.crawlDelay > crawlDelay {
+				crawlDelay = group.crawlDelay
+			}
+		}
+	}
+
+	return
+}
+
+//
+// Allowed : Is the path (including any query) allowed for the user agent
+//
+func (id *Robots) Allowed(userAgent string, path string) bool {
+	if id.disallowAll {
+		return false
+	}
+
+	if len(path) == 0 {
+		path = "/"
+	}
+
+	// the longest matching pattern wins, allow wins ties
+	rules, _ := id.match(userAgent)
+	allowed := true
+	longest := -1
+	for _, rule := range rules {
+		if _robotsPatternMatch(rule.pattern, path) {
+			if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+				longest = len(rule.pattern)
+				allowed = rule.allow
+			}
+		}
+	}
+
+	return allowed
+}
+
+//
+// CrawlDelay : The Crawl-delay for the user agent, zero if unspecified
+//
+func (id *Robots) CrawlDelay(userAgent string) time.Duration {
+	_, crawlDelay := id.match(userAgent)
+	return crawlDelay
+}
+
+//
+// robots.txt: Match path against a pattern with "*" wildcards and a "$" end anchor
+//
+func _robotsPatternMatch(pattern string, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+
+	segments := strings.Split(pattern, "*")
+
+	// the first segment is a prefix
+	if !strings.HasPrefix(path, segments[0]) {
+		return false
+	}
+	position := len(segments[0])
+
+	for i := 1; i < len(segments); i++ {
+		segment := segments[i]
+		if i == len(segments)-1 && anchored {
+			// the final segment must end the path
+			return len(path)-position >= len(segment) && strings.HasSuffix(path, segment)
+		}
+		idx := strings.Index(path[position:], segment)
+		if idx == -1 {
+			return false
+		}
+		position += idx + len(segment)
+	}
+
+	if anchored {
+		return position == len(path)
+	}
+
+	return true
+}
+
+//
+// robots.txt cache entry
+//
+type _robotsEntry struct {
+	robots  *Robots
+	fetched time.Time
+}
+
+//
+// RobotsCache def
+// Fetches and caches robots.txt per scheme and host for TTL.
+//
+type RobotsCache struct {
+	TTL     time.Duration
+	TLS     *TLSConfig
+	entries map[string]*_robotsEntry
+	lock    sync.Mutex
+}
+
+//
+// NewRobotsCache constructor
+//
+func NewRobotsCache() *RobotsCache {
+	return &RobotsCache{TTL: 24 * time.Hour, entries: make(map[string]*_robotsEntry)}
+}
+
+//
+// Get : The robots.txt rules for the host of u, fetched if not cached
+//
+func (id *RobotsCache) Get(u *url.URL, userAgent string) *Robots {
+	key := strings.ToLower(u.Scheme + "://" + u.Host)
+
+	id.lock.Lock()
+	entry := id.entries[key]
+	id.lock.Unlock()
+
+	if entry != nil && time.Since(entry.fetched) < id.TTL {
+		return entry.robots
+	}
+
+	robots := id.fetch(key+"/robots.txt", userAgent)
+
+	id.lock.Lock()
+	id.entries[key] = &_robotsEntry{robots: robots, fetched: time.Now()}
+	id.lock.Unlock()
+
+	return robots
+}
+
+//
+// RobotsCache: Fetch and parse a robots.txt (RFC 9309 2.3.1)
+// 4xx means no restrictions, while 5xx and unreachable servers disallow everything.
+//
+func (id *RobotsCache) fetch(robotsURL string, userAgent string) *Robots {
+	LogDebug("Fetching " + robotsURL)
+
+	fetcher := NewHTTP()
+	fetcher.TLS = id.TLS
+	fetcher.UserAgent = userAgent
+	contents := fetcher.Get(robotsURL)
+
+	status := fetcher.Status()
+	switch {
+	case fetcher.LastError() != nil || status >= 500:
+		return &Robots{disallowAll: true}
+	case status >= 400:
+		return &Robots{}
+	}
+
+	return ParseRobots(contents)
+}
+
+//
+// Allowed : Is u allowed for the user agent
+//
+func (id *RobotsCache) Allowed(u *url.URL, userAgent string) bool {
+	path := u.EscapedPath()
+	if len(u.RawQuery) > 0 {
+		path += "?" + u.RawQuery
+	}
+
+	return id.Get(u, userAgent).Allowed(userAgent, path)
+}
+
+//
+// HTTP: Refuse the request if the robots.txt rules disallow it
+//
+func (id *HTTP) robotsCheck(userAgent string) error {
+	if id.Robots == nil || id.URL == nil || id.URL.Path == "/robots.txt" {
+		return nil
+	}
+
+	robots := id.Robots.Get(id.URL, userAgent)
+
+	if id.RateLimiter != nil {
+		if crawlDelay := robots.CrawlDelay(userAgent); crawlDelay > 0 {
+			id.RateLimiter.SetCrawlDelay(id.URL.Host, crawlDelay)
+		}
+	}
+
+	if !id.Robots.Allowed(id.URL, userAgent) {
+		return &RobotsDisallowedError{URL: id.URLString(), UserAgent: userAgent}
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const robotsTestContents = `# example
+User-agent: *
+Disallow: /private
+Allow: /private/public
+Disallow: /*.pdf$
+Crawl-delay: 2
+
+User-agent: goweb
+User-agent: otherbot
+Disallow: /
+
+Sitemap: http://example.com/sitemap.xml
+`
+
+func TestRobotsAllowed(t *testing.T) {
+	r := ParseRobots(robotsTestContents)
+
+	cases := map[string]bool{
+		"/":                    true,
+		"/private":             false,
+		"/private/x":           false,
+		"/private/public/page": true,
+		"/docs/a.pdf":          false,
+		"/docs/a.pdf?x=1":      true,
+	}
+	for path, expected := range cases {
+		if r.Allowed("Mozilla/5.0", path) != expected {
+			t.Errorf("Allowed %s vs expected %t", path, expected)
+		}
+	}
+
+	if r.Allowed("goweb/1.0", "/") {
+		t.Errorf("goweb group not applied")
+	}
+	if r.Allowed("Mozilla/5.0 (compatible; OtherBot/2.1; +http://example.com/bot.html)", "/") {
+		t.Errorf("otherbot group not applied to its product token")
+	}
+	// the agent line is a product token, not a substring of the user agent
+	if !r.Allowed("Mozilla/5.0 (compatible; Motherbot/2.1)", "/") || !r.Allowed("gowebber/1.0", "/") {
+		t.Errorf("group applied to a partial product token")
+	}
+	if r.CrawlDelay("Mozilla/5.0") != 2*time.Second {
+		t.Errorf("Crawl-delay %s vs expected %s", r.CrawlDelay("Mozilla/5.0"), 2*time.Second)
+	}
+	if len(r.Sitemaps) != 1 {
+		t.Errorf("Sitemaps %v", r.Sitemaps)
+	}
+}
+
+func TestRobotsEnforcement(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, robotsTestContents)
+			return
+		}
+		fmt.Fprint(w, "page")
+	}))
+	defer server.Close()
+
+	x := NewHTTP()
+	x.UserAgent = "Mozilla/5.0"
+	x.Robots = NewRobotsCache()
+
+	if r := x.Get(server.URL + "/open"); r != "page" {
+		t.Errorf("Allowed result [%s] %v", r, x.LastError())
+	}
+
+	x.Get(server.URL + "/private/x")
+	var disallowed *RobotsDisallowedError
+	if !errors.As(x.LastError(), &disallowed) {
+		t.Errorf("Disallowed URL not refused %v", x.LastError())
+	}
+
+	// only the root robots.txt is exempt from the rules
+	x.Get(server.URL + "/private/robots.txt")
+	if !errors.As(x.LastError(), &disallowed) {
+		t.Errorf("Disallowed robots.txt path not refused %v", x.LastError())
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"sort"
+	"strings"
+)
+
+//
+// one compound step of a selector, such as span.price or a[rel=next]
+//
+type _selectorStep struct {
+	tag        string
+	id         string
+	classes    []string
+	attributes DOMNodeAttributes
+}
+
+//
+// Select : Find the elements matching a selector
+// Selectors are descendant chains of steps, each step a tag, #id, .class and
+// [attr] or [attr=value] in any combination, as in "div.product h1.title".
+//
+func (id *DOM) Select(selector string) (result []*DOMNode) {
+	return id.ChildSelect(id.RootNode(), selector)
+}
+
+//
+// ChildSelect : Find the descendants of parent matching a selector
+//
+func (id *DOM) ChildSelect(parent *DOMNode, selector string) (result []*DOMNode) {
+	steps := _parseSelector(selector)
+	if len(steps) == 0 {
+		return nil
+	}
+
+	scopes := []*DOMNode{parent}
+	for _, step := range steps {
+		seen := map[*DOMNode]bool{}
+		matches := []*DOMNode{}
+		for _, scope := range scopes {
+			for _, node := range id._selectStep(scope, step) {
+				if !seen[node] {
+					seen[node] = true
+					matches = append(matches, node)
+				}
+			}
+		}
+		sort.Slice(matches, func(i, j int) bool { return matches[i].Index < matches[j].Index })
+		scopes = matches
+	}
+
+	return scopes
+}
+
+//
+// DOM: The strict descendants of scope matching one step
+//
+func (id *DOM) _selectStep(scope *DOMNode, step _selectorStep) (result []*DOMNode) {
+	candidates := id.nodes[step.tag]
+	if len(step.tag) == 0 {
+		candidates = id.document
+	}
+
+	for _, node := range candidates {
+		if node != scope && node.IsElement() && step.match(node) && id.IsDescendantNode(scope, node) {
+			result = append(result, node)
+		}
+	}
+
+	return result
+}
+
+func (id *_selectorStep) match(node *DOMNode) bool {
+	if len(id.id) > 0 && node.Attr("id") != id.id {
+		return false
+	}
+
+	classes := strings.Fields(node.Attr("class"))
+	for _, class := range id.classes {
+		found := false
+		for _, candidate := range classes {
+			if candidate == class {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for key, value := range id.attributes {
+		actual, ok := node.Attributes[key]
+		if !ok || (len(value) > 0 && actual != value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+//
+// Selector: Split a selector into its steps, a malformed step yields none
+//
+func _parseSelector(selector string) (steps []_selectorStep) {
+	parts := _selectorParts(selector)
+	if parts == nil {
+		return nil
+	}
+
+	for _, part := range parts {
+		step := _selectorStep{attributes: DOMNodeAttributes{}}
+		for len(part) > 0 {
+			end := strings.IndexAny(part[1:], ".#[") + 1
+			if end == 0 {
+				end = len(part)
+			}
+
+			token := part[:end]
+			switch token[0] {
+			case '.':
+				step.classes = append(step.classes, token[1:])
+			case '#':
+				step.id = token[1:]
+			case '[':
+				// the attribute value may itself hold . # ] or spaces when quoted
+				close := _selectorClose(part)
+				if close < 0 {
+					return nil
+				}
+				token, end = part[1:close], close+1
+				key, value := token, ""
+				if idx := strings.Index(token, "="); idx > -1 {
+					key, value = token[:idx], strings.Trim(token[idx+1:], `"'`)
+				}
+				step.attributes[strings.ToLower(key)] = value
+			default:
+				if token != "*" {
+					step.tag = strings.ToLower(token)
+				}
+			}
+			part = part[end:]
+		}
+		steps = append(steps, step)
+	}
+
+	return steps
+}
+
+//
+// Selector: Split a selector on the whitespace between steps
+// Whitespace inside [...] belongs to the step, nil when a bracket is left open.
+//
+func _selectorParts(selector string) (parts []string) {
+	parts = []string{}
+	start := -1
+	for i := 0; i < len(selector); i++ {
+		switch c := selector[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			if start > -1 {
+				parts = append(parts, selector[start:i])
+				start = -1
+			}
+		case c == '[':
+			if start < 0 {
+				start = i
+			}
+			close := _selectorClose(selector[i:])
+			if close < 0 {
+				return nil
+			}
+			i += close
+		default:
+			if start < 0 {
+				start = i
+			}
+		}
+	}
+	if start > -1 {
+		parts = append(parts, selector[start:])
+	}
+
+	return parts
+}
+
+//
+// Selector: The index of the ] closing the [ at the start of s, skipping quoted values, -1 if none
+//
+func _selectorClose(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ']':
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,264 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+)
+
+//
+// Session def
+// A Session is safe for concurrent use: each call executes on its own HTTP
+// exchange while the cookie jar, cache, rate limiter and robots cache are
+// shared. Configure the exported fields before the first request.
+//
+type Session struct {
+	TLS         *TLSConfig
+	Cache       *HTTPCache
+	RateLimiter *RateLimiter
+	Robots      *RobotsCache
+	UserAgent   string
+	Header      http.Header
+	TimingHooks []TimingHook
+	Timeouts    *Timeouts
+
+	cookieJar  http.CookieJar
+	gaeRequest *http.Request
+}
+
+//
+// NewSession constructor
+//
+func NewSession() *Session {
+	id := &Session{Header: make(http.Header)}
+	id.cookieJar, _ = cookiejar.New(nil)
+
+	return id
+}
+
+func (id *Session) SetGAERequest(req *http.Request) {
+	id.gaeRequest = req
+}
+
+//
+// CookieJar : The jar shared by every request of the session
+//
+func (id *Session) CookieJar() http.CookieJar {
+	return id.cookieJar
+}
+
+//
+// HTTP : A new HTTP bound to the session configuration and cookies
+// The HTTP itself is single goroutine, use one per goroutine.
+//
+func (id *Session) HTTP() *HTTP {
+	h := NewHTTP()
+	h.cookieJar = id.cookieJar
+	h.gaeRequest = id.gaeRequest
+	h.TLS = id.TLS
+	h.Cache = id.Cache
+	h.RateLimiter = id.RateLimiter
+	h.Robots = id.Robots
+	h.UserAgent = id.UserAgent
+	h.TimingHooks = id.TimingHooks
+	h.Timeouts = id.Timeouts
+	h.Header = id.Header.Clone()
+	if h.Header == nil {
+		h.Header = make(http.Header)
+	}
+
+	return h
+}
+
+//
+// Request def
+//
+type Request struct {
+	Method      string
+	URL         string
+	ContentType string
+	Body        []byte
+	Header      http.Header
+	// Timeouts overrides the session timeouts for this request
+	Timeouts *Timeouts
+}
+
+//
+// NewRequest constructor
+//
+func NewRequest(method string, urlString string) *Request {
+	return &Request{Method: strings.ToUpper(method), URL: urlString, Header: make(http.Header)}
+}
+
+//
+// Response def
+//
+type Response struct {
+	Request    *Request
+	URL        string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	TLS        *TLSState
+	Timing     *RequestTiming
+}
+
+//
+// Contents : The response body as a string
+//
+func (id *Response) Contents() string {
+	return string(id.Body)
+}
+
+//
+// ContentType : The Content-Type header
+//
+func (id *Response) ContentType() string {
+	return id.Header.Get("Content-Type")
+}
+
+//
+// Location : The Location header
+//
+func (id *Response) Location() string {
+	return id.Header.Get("Location")
+}
+
+//
+// JSON : Marshall the body from JSON to a map if possible
+//
+func (id *Response) JSON() (result map[string]interface{}, err error) {
+	err = json.Unmarshal(id.Body, &result)
+
+	return
+}
+
+//
+// DecodeJSON : Decode the JSON body into target, a pointer to any type
+//
+func (id *Response) DecodeJSON(target interface{}, options ...JSONDecodeOption) error {
+	return DecodeJSON(id.Body, target, options...)
+}
+
+//
+// DOM : Parse the body into a DOM
+//
+func (id *Response) DOM() *DOM {
+	d := NewDOM()
+	d.SetContents(id.Contents())
+
+	return d
+}
+
+//
+// Do : Execute the request, following redirects, on its own exchange
+//
+func (id *Session) Do(req *Request) (resp *Response, err error) {
+	h := id.HTTP()
+	if req.Timeouts != nil {
+		h.Timeouts = req.Timeouts
+	}
+	for key, values := range req.Header {
+		h.Header[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+	}
+
+	var content *bytes.Buffer
+	if req.Body != nil {
+		content = bytes.NewBuffer(req.Body)
+	}
+
+	h.DoContent(req.Method, req.URL, req.ContentType, content)
+	if err = h.LastError(); err != nil {
+		return nil, err
+	}
+
+	resp = &Response{
+		Request:    req,
+		URL:        h.URLString(),
+		StatusCode: h.Status(),
+		Body:       h.RawContents,
+		TLS:        h.TLSState(),
+		Timing:     h.Timing(),
+	}
+	if h.resp != nil {
+		resp.Header = h.resp.Header
+	} else {
+		resp.Header = make(http.Header)
+	}
+
+	return resp, nil
+}
+
+//
+// Get : GET request
+//
+func (id *Session) Get(urlString string) (*Response, error) {
+	return id.Do(NewRequest(HTTP_GET, urlString))
+}
+
+//
+// GetQuery : GET request with the args encoded into the query
+//
+func (id *Session) GetQuery(urlString string, args map[string]string) (*Response, error) {
+	target, err := url.Parse(urlString)
+	if err != nil {
+		return nil, err
+	}
+
+	query := target.Query()
+	for key, val := range args {
+		query.Add(key, val)
+	}
+	target.RawQuery = query.Encode()
+
+	return id.Do(NewRequest(HTTP_GET, target.String()))
+}
+
+//
+// Head : HEAD request
+//
+func (id *Session) Head(urlString string) (*Response, error) {
+	return id.Do(NewRequest(HTTP_HEAD, urlString))
+}
+
+//
+// Post : POST request with a typed body
+//
+func (id *Session) Post(urlString string, contentType string, body []byte) (*Response, error) {
+	req := NewRequest(HTTP_POST, urlString)
+	req.ContentType = contentType
+	req.Body = body
+
+	return id.Do(req)
+}
+
+//
+// PostForm : POST request with url encoded form args
+//
+func (id *Session) PostForm(urlString string, args map[string]string) (*Response, error) {
+	form := url.Values{}
+	for key, val := range args {
+		form.Add(key, val)
+	}
+
+	return id.Post(urlString, CONTENT_TYPE_FORM, []byte(form.Encode()))
+}
+
+//
+// PostJSON : POST request with v marshalled as the JSON body
+//
+func (id *Session) PostJSON(urlString string, v interface{}) (*Response, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return id.Post(urlString, CONTENT_TYPE_JSON, body)
+}
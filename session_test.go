@@ -0,0 +1,118 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// run with -race (make race) to prove concurrent use is safe
+func TestSessionConcurrent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+			fmt.Fprint(w, "ok")
+			return
+		}
+		if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "abc" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer server.Close()
+
+	s := NewSession()
+	s.Cache = NewHTTPCache(NewMemoryCache(1 << 20))
+	s.RateLimiter = NewRateLimiter(0, 1)
+	s.RateLimiter.MaxConcurrency = 4
+
+	if _, err := s.Get(server.URL + "/login"); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan string, 64)
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			path := fmt.Sprintf("/page/%d", i%8)
+			resp, err := s.Get(server.URL + path)
+			if err != nil {
+				errs <- err.Error()
+			} else if resp.StatusCode != 200 || resp.Contents() != path {
+				errs <- fmt.Sprintf("%s status %d [%s]", path, resp.StatusCode, resp.Contents())
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestSessionRequest(t *testing.T) {
+	server := newEchoTestServer()
+	defer server.Close()
+
+	s := NewSession()
+	req := NewRequest(HTTP_PUT, server.URL+"/echo")
+	req.Body = []byte("x")
+	req.Header.Set("X-Test", "1")
+
+	resp, err := s.Do(req)
+	if err != nil || resp.Contents() != "PUT x" || resp.Request != req {
+		t.Errorf("Session result [%v] %v", resp, err)
+	}
+}
+
+// run with -race, responses served from the cache must not share its state
+func TestSessionCachedResponseCopy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprint(w, "cached")
+	}))
+	defer server.Close()
+
+	s := NewSession()
+	s.Cache = NewHTTPCache(NewMemoryCache(1 << 20))
+	if _, err := s.Get(server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := s.Get(server.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Header.Set("Content-Type", fmt.Sprintf("text/x-%d", i))
+			resp.Header.Add("Cache-Control", "private")
+			if len(resp.Body) > 0 {
+				resp.Body[0] = 'X'
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	resp, err := s.Get(server.URL)
+	if err != nil || resp.Contents() != "cached" || resp.ContentType() != "text/plain" || len(resp.Header.Values("Cache-Control")) != 1 {
+		t.Errorf("cache entry altered through a response [%s] %v %v", resp.Contents(), resp.Header, err)
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	. "golog"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SITEMAP_MAX_DEPTH bounds the recursion through nested sitemap indexes
+const SITEMAP_MAX_DEPTH = 4
+
+// SITEMAP_MAX_SIZE is the uncompressed size limit of a sitemap (sitemaps.org)
+const SITEMAP_MAX_SIZE = 50 << 20
+
+var (
+	// ErrSitemapNotFound is returned when no sitemap could be discovered for a site
+	ErrSitemapNotFound = errors.New("goweb: no sitemap found")
+	// ErrSitemapTooBig is returned when a gzipped sitemap inflates past SITEMAP_MAX_SIZE
+	ErrSitemapTooBig = errors.New("goweb: sitemap too big")
+)
+
+//
+// SitemapURL def
+//
+type SitemapURL struct {
+	Loc        string
+	LastMod    time.Time
+	ChangeFreq string
+	Priority   float64
+}
+
+//
+// sitemaps.org XML schema, only the fields of interest
+//
+type _sitemapXML struct {
+	XMLName  xml.Name
+	URLs     []_sitemapXMLEntry `xml:"url"`
+	Sitemaps []_sitemapXMLEntry `xml:"sitemap"`
+}
+
+type _sitemapXMLEntry struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod"`
+	ChangeFreq string `xml:"changefreq"`
+	Priority   string `xml:"priority"`
+}
+
+// W3C datetime profiles permitted by sitemaps.org
+var _sitemapTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+//
+// ParseSitemapTime : Parse a W3C datetime lastmod value
+//
+func ParseSitemapTime(value string) (result time.Time, err error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range _sitemapTimeLayouts {
+		result, err = time.Parse(layout, value)
+		if err == nil {
+			return
+		}
+	}
+
+	return
+}
+
+//
+// ParseSitemap : Parse an XML sitemap, sitemap index or plain text sitemap, optionally gzipped
+// The page URLs are returned in urls, any nested sitemap URLs in sitemaps.
+//
+func ParseSitemap(data []byte) (urls []SitemapURL, sitemaps []string, err error) {
+	// gzip magic number, the transport only decodes gzip it negotiated itself
+	if len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err = ioutil.ReadAll(io.LimitReader(reader, SITEMAP_MAX_SIZE+1))
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(data) > SITEMAP_MAX_SIZE {
+			return nil, nil, ErrSitemapTooBig
+		}
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if !bytes.HasPrefix(trimmed, []byte("<")) {
+		return _parseTextSitemap(trimmed), nil, nil
+	}
+
+	sitemap := _sitemapXML{}
+	if err = xml.Unmarshal(trimmed, &sitemap); err != nil {
+		return nil, nil, err
+	}
+
+	for _, entry := range sitemap.URLs {
+		loc := strings.TrimSpace(entry.Loc)
+		if len(loc) == 0 {
+			continue
+		}
+
+		sitemapURL := SitemapURL{Loc: loc, ChangeFreq: strings.ToLower(strings.TrimSpace(entry.ChangeFreq)), Priority: 0.5}
+		if len(entry.LastMod) > 0 {
+			sitemapURL.LastMod, _ = ParseSitemapTime(entry.LastMod)
+		}
+		if priority, err := strconv.ParseFloat(strings.TrimSpace(entry.Priority), 64); err == nil {
+			sitemapURL.Priority = priority
+		}
+		urls = append(urls, sitemapURL)
+	}
+
+	for _, entry := range sitemap.Sitemaps {
+		if loc := strings.TrimSpace(entry.Loc); len(loc) > 0 {
+			sitemaps = append(sitemaps, loc)
+		}
+	}
+
+	return urls, sitemaps, nil
+}
+
+//
+// Sitemap: one absolute URL per line
+//
+func _parseTextSitemap(data []byte) (urls []SitemapURL) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "http://") || strings.HasPrefix(line, "https://") {
+			urls = append(urls, SitemapURL{Loc: line, Priority: 0.5})
+		}
+	}
+
+	return urls
+}
+
+//
+// DiscoverSitemaps : The sitemap URLs listed in robots.txt, otherwise /sitemap.xml
+//
+func (id *HTTP) DiscoverSitemaps(siteURL string) (result []string, err error) {
+	site, err := url.Parse(siteURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(site.Scheme) == 0 {
+		site, _ = url.Parse("http://" + siteURL)
+	}
+	root := site.Scheme + "://" + site.Host
+
+	id.URL = nil
+	contents := id.Get(root + "/robots.txt")
+	if id.LastError() == nil && id.Status() == 200 {
+		result = ParseRobots(contents).Sitemaps
+	}
+
+	if len(result) == 0 {
+		result = []string{root + "/sitemap.xml"}
+	}
+
+	return result, nil
+}
+
+//
+// Sitemap : Fetch and parse the sitemap at sitemapURL, following nested sitemap indexes
+//
+func (id *HTTP) Sitemap(sitemapURL string) (result []SitemapURL, err error) {
+	return id.fetchSitemap(sitemapURL, 0, make(map[string]bool))
+}
+
+//
+// SiteURLs : Discover and fetch every sitemap of the site
+//
+func (id *HTTP) SiteURLs(siteURL string) (result []SitemapURL, err error) {
+	sitemapURLs, err := id.DiscoverSitemaps(siteURL)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	found := false
+	for _, sitemapURL := range sitemapURLs {
+		urls, fetchErr := id.fetchSitemap(sitemapURL, 0, seen)
+		if fetchErr != nil {
+			LogWarn("Sitemap " + sitemapURL + ": " + fetchErr.Error())
+			continue
+		}
+		found = true
+		result = append(result, urls...)
+	}
+
+	if !found {
+		err = ErrSitemapNotFound
+	}
+
+	return result, err
+}
+
+//
+// HTTP: Fetch a sitemap, recursing through indexes up to SITEMAP_MAX_DEPTH
+//
+func (id *HTTP) fetchSitemap(sitemapURL string, depth int, seen map[string]bool) (result []SitemapURL, err error) {
+	if seen[sitemapURL] || depth > SITEMAP_MAX_DEPTH {
+		return nil, nil
+	}
+	seen[sitemapURL] = true
+
+	id.URL = nil
+	id.Get(sitemapURL)
+	if err = id.LastError(); err != nil {
+		return nil, err
+	}
+	if id.Status() != 200 {
+		return nil, errors.New("goweb: sitemap status " + strconv.Itoa(id.Status()))
+	}
+
+	urls, sitemaps, err := ParseSitemap(id.RawContents)
+	if err != nil {
+		return nil, err
+	}
+	result = urls
+
+	for _, nested := range sitemaps {
+		nestedURLs, nestedErr := id.fetchSitemap(nested, depth+1, seen)
+		if nestedErr != nil {
+			LogWarn("Sitemap " + nested + ": " + nestedErr.Error())
+			continue
+		}
+		result = append(result, nestedURLs...)
+	}
+
+	return result, nil
+}
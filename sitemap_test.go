@@ -0,0 +1,87 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const sitemapTestURLSet = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url><loc>http://example.com/a</loc><lastmod>2016-11-02</lastmod><changefreq>Daily</changefreq><priority>0.8</priority></url>
+	<url><loc>http://example.com/b</loc></url>
+</urlset>`
+
+func TestParseSitemap(t *testing.T) {
+	urls, sitemaps, err := ParseSitemap([]byte(sitemapTestURLSet))
+	if err != nil || len(urls) != 2 || len(sitemaps) != 0 {
+		t.Fatalf("Error %v [%v] [%v]", err, urls, sitemaps)
+	}
+	if urls[0].Priority != 0.8 || urls[0].ChangeFreq != "daily" || urls[0].LastMod.Year() != 2016 {
+		t.Errorf("Sitemap entry %+v", urls[0])
+	}
+	if urls[1].Priority != 0.5 {
+		t.Errorf("Sitemap default priority %f vs expected %f", urls[1].Priority, 0.5)
+	}
+
+	urls, _, _ = ParseSitemap([]byte("http://example.com/a\n\nhttp://example.com/b\n"))
+	if len(urls) != 2 {
+		t.Errorf("Text sitemap length %d vs expected %d", len(urls), 2)
+	}
+}
+
+func TestParseSitemapGzipLimit(t *testing.T) {
+	// a small download inflating past the limit
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	chunk := make([]byte, 1<<20)
+	for written := 0; written <= SITEMAP_MAX_SIZE; written += len(chunk) {
+		writer.Write(chunk)
+	}
+	writer.Close()
+
+	if _, _, err := ParseSitemap(compressed.Bytes()); err != ErrSitemapTooBig {
+		t.Errorf("expected ErrSitemapTooBig, got %v", err)
+	}
+}
+
+func TestSiteURLs(t *testing.T) {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write([]byte(sitemapTestURLSet))
+	writer.Close()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprintf(w, "User-agent: *\nSitemap: %s/index.xml\n", server.URL)
+		case "/index.xml":
+			w.Header().Set("Content-Type", "text/xml")
+			fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%s/a.xml.gz</loc></sitemap><sitemap><loc>%s/b.txt</loc></sitemap></sitemapindex>`, server.URL, server.URL)
+		case "/a.xml.gz":
+			w.Header().Set("Content-Type", "application/x-gzip")
+			w.Write(compressed.Bytes())
+		case "/b.txt":
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, "http://example.com/c\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	x := NewHTTP()
+	urls, err := x.SiteURLs(server.URL)
+	if err != nil || len(urls) != 3 {
+		t.Errorf("Error %v [%v]", err, urls)
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	. "golog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CONTENT_TYPE_EVENT_STREAM = "text/event-stream"
+	// SSE_DEFAULT_RETRY is the reconnection delay until the server sends retry:
+	SSE_DEFAULT_RETRY = 3 * time.Second
+)
+
+//
+// SSEEvent def
+//
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  string
+	Retry time.Duration
+}
+
+//
+// SSEStream def
+// Events are delivered on Events, which is closed once the stream ends
+// either by Close or by a fatal response (non 200, wrong content type, 204).
+//
+type SSEStream struct {
+	Events <-chan *SSEEvent
+
+	events      chan *SSEEvent
+	client      *http.Client
+	request     *http.Request
+	ctx         context.Context
+	cancel      context.CancelFunc
+	lastEventID string
+	retry       time.Duration
+	err         error
+	lock        sync.Mutex
+}
+
+//
+// EventStream : Open a Server-Sent Events stream, reconnecting with Last-Event-ID
+// The stream shares the cookies, headers, proxy and TLS settings of the HTTP.
+//
+func (id *HTTP) EventStream(urlString string) (stream *SSEStream, err error) {
+	req, err := id.streamRequest(HTTP_GET, urlString)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", CONTENT_TYPE_EVENT_STREAM)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	stream = &SSEStream{
+		events:  make(chan *SSEEvent, 16),
+		client:  id.streamClient(),
+		request: req,
+		retry:   SSE_DEFAULT_RETRY,
+	}
+	stream.Events = stream.events
+	stream.ctx, stream.cancel = context.WithCancel(context.Background())
+
+	go stream.run()
+
+	return stream, nil
+}
+
+//
+// Close : End the stream, Events is closed once the reader goroutine exits
+//
+func (id *SSEStream) Close() {
+	id.cancel()
+}
+
+//
+// Err : The error that ended the stream, nil if closed by the caller
+//
+func (id *SSEStream) Err() error {
+	id.lock.Lock()
+	defer id.lock.Unlock()
+
+	return id.err
+}
+
+//
+// LastEventID : The id of the last event received
+//
+func (id *SSEStream) LastEventID() string {
+	id.lock.Lock()
+	defer id.lock.Unlock()
+
+	return id.lastEventID
+}
+
+//
+// SSE: Connect and read until closed, sleeping retry between connections
+//
+func (id *SSEStream) run() {
+	defer close(id.events)
+
+	for {
+		fatal := id.connect()
+		if id.ctx.Err() != nil {
+			return
+		}
+		if fatal != nil {
+			id.lock.Lock()
+			id.err = fatal
+			id.lock.Unlock()
+			return
+		}
+
+		id.lock.Lock()
+		retry := id.retry
+		id.lock.Unlock()
+
+		LogDebugf("SSE reconnecting in %s", retry)
+		select {
+		case <-id.ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+//
+// SSE: One connection, returns a non nil error only when reconnecting is pointless
+//
+func (id *SSEStream) connect() (fatal error) {
+	req := id.request.Clone(id.ctx)
+	if lastEventID := id.LastEventID(); len(lastEventID) > 0 {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := id.client.Do(req)
+	if err != nil {
+		if id.ctx.Err() != nil {
+			return id.ctx.Err()
+		}
+		LogWarn("SSE connection failed: " + err.Error())
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return fmt.Errorf("goweb: event stream ended by server (status %d)", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("goweb: event stream status %d", resp.StatusCode)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), CONTENT_TYPE_EVENT_STREAM) {
+		return fmt.Errorf("goweb: event stream content type %s", resp.Header.Get("Content-Type"))
+	}
+
+	id.read(bufio.NewReader(resp.Body))
+
+	if id.ctx.Err() != nil {
+		return id.ctx.Err()
+	}
+
+	return nil
+}
+
+//
+// SSE: Parse the stream per the HTML event-stream interpretation rules
+//
+func (id *SSEStream) read(reader *bufio.Reader) {
+	event := &SSEEvent{}
+	data := []string{}
+	hasData := false
+	afterCR := false
+
+	for {
+		line, endCR, err := _readSSELine(reader, afterCR)
+		if err != nil {
+			// an incomplete trailing event is discarded
+			return
+		}
+		afterCR = endCR
+
+		if len(line) == 0 {
+			// dispatch
+			if hasData {
+				event.Data = strings.Join(data, "\n")
+				if len(event.Event) == 0 {
+					event.Event = "message"
+				}
+				event.ID = id.LastEventID()
+				select {
+				case id.events <- event:
+				case <-id.ctx.Done():
+					return
+				}
+			}
+			event = &SSEEvent{}
+			data = data[:0]
+			hasData = false
+			continue
+		}
+
+		if strings.HasPrefix(line, ":") {
+			// comment
+			continue
+		}
+
+		field, value := line, ""
+		if idx := strings.Index(line, ":"); idx > -1 {
+			field = line[:idx]
+			value = strings.TrimPrefix(line[idx+1:], " ")
+		}
+
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data = append(data, value)
+			hasData = true
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				id.lock.Lock()
+				id.lastEventID = value
+				id.lock.Unlock()
+			}
+		case "retry":
+			if millis, err := strconv.Atoi(value); err == nil && _isASCIIDigits(value) {
+				event.Retry = time.Duration(millis) * time.Millisecond
+				id.lock.Lock()
+				id.retry = event.Retry
+				id.lock.Unlock()
+			}
+		}
+	}
+}
+
+//
+// SSE: One line ended by LF, CRLF or a lone CR, endCR reports a CR ending
+// The LF of a CRLF split across reads is skipped by the next call through
+// afterCR, peeking past the CR would stall the dispatch of a finished event.
+//
+func _readSSELine(reader *bufio.Reader, afterCR bool) (line string, endCR bool, err error) {
+	var buffer strings.Builder
+	for {
+		c, err := reader.ReadByte()
+		if err != nil {
+			return "", false, err
+		}
+		if afterCR {
+			afterCR = false
+			if c == '\n' {
+				continue
+			}
+		}
+
+		switch c {
+		case '\n':
+			return buffer.String(), false, nil
+		case '\r':
+			return buffer.String(), true, nil
+		}
+		buffer.WriteByte(c)
+	}
+}
+
+//
+// SSE: retry: values are ASCII digits only, no sign
+//
+func _isASCIIDigits(value string) bool {
+	if len(value) == 0 {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if value[i] < '0' || value[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
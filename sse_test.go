@@ -0,0 +1,104 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEventStream(t *testing.T) {
+	var lock sync.Mutex
+	lastEventIDs := []string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		connection := len(lastEventIDs)
+		lock.Unlock()
+
+		if cookie, err := r.Cookie("auth"); err != nil || cookie.Value != "1" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Content-Type", CONTENT_TYPE_EVENT_STREAM)
+		if connection == 1 {
+			fmt.Fprint(w, ": comment\nretry: 10\nid: 1\nevent: update\ndata: line one\ndata: line two\n\n")
+			return
+		}
+		fmt.Fprint(w, "id: 2\ndata: resumed\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	x := NewHTTP()
+	serverURL, _ := url.Parse(server.URL)
+	x.cookieJar.SetCookies(serverURL, []*http.Cookie{{Name: "auth", Value: "1"}})
+
+	stream, err := x.EventStream(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	expected := []SSEEvent{
+		{ID: "1", Event: "update", Data: "line one\nline two", Retry: 10 * time.Millisecond},
+		{ID: "2", Event: "message", Data: "resumed"},
+	}
+	for _, want := range expected {
+		select {
+		case event := <-stream.Events:
+			if *event != want {
+				t.Errorf("Event %+v vs expected %+v", *event, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Event timeout, stream error %v", stream.Err())
+		}
+	}
+
+	lock.Lock()
+	if len(lastEventIDs) != 2 || lastEventIDs[1] != "1" {
+		t.Errorf("Last-Event-ID headers %v", lastEventIDs)
+	}
+	lock.Unlock()
+}
+
+func TestEventStreamLineEndings(t *testing.T) {
+	stream := &SSEStream{events: make(chan *SSEEvent, 16), ctx: context.Background(), retry: SSE_DEFAULT_RETRY}
+	// CR, CRLF and LF endings mixed, signed retry values ignored
+	stream.read(bufio.NewReader(strings.NewReader("retry: -5\rretry: +5\r\ndata: one\r\rdata: two\r\ndata: three\r\n\r\nretry: 20\ndata: four\n\n")))
+	close(stream.events)
+
+	expected := []SSEEvent{
+		{Event: "message", Data: "one"},
+		{Event: "message", Data: "two\nthree"},
+		{Event: "message", Data: "four", Retry: 20 * time.Millisecond},
+	}
+	received := []SSEEvent{}
+	for event := range stream.events {
+		received = append(received, *event)
+	}
+	if len(received) != len(expected) {
+		t.Fatalf("Events %+v", received)
+	}
+	for i, want := range expected {
+		if received[i] != want {
+			t.Errorf("Event %+v vs expected %+v", received[i], want)
+		}
+	}
+	if stream.retry != 20*time.Millisecond {
+		t.Errorf("Retry %s", stream.retry)
+	}
+}
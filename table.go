@@ -0,0 +1,407 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// TABLE_MAX_SPAN caps rowspan and colspan, as browsers do
+const TABLE_MAX_SPAN = 1000
+
+var (
+	// ErrNotTable is returned when Table is called on an element other than a table
+	ErrNotTable = errors.New("goweb: node is not a table")
+	// ErrTableTarget is returned when Unmarshal is not given a pointer to a slice of structs
+	ErrTableTarget = errors.New("goweb: table target must be a pointer to a slice of structs")
+)
+
+// commas only between groups of three digits, as in 1,234,567.89
+var _tableGroupedNumber = regexp.MustCompile(`^[+-]?\d{1,3}(?:,\d{3})+(?:\.\d*)?$`)
+
+//
+// Table def
+// The cells of a table laid out on a grid, spanning cells repeated in every
+// slot they cover. Cells holds the td or th node behind each slot, the way to
+// reach the tables nested in a cell, whose text is left out of the cell text.
+//
+type Table struct {
+	Caption string
+	Header  []string
+	Rows    [][]string
+	Cells   [][]*DOMNode
+}
+
+//
+// Table : The table as a grid of header and body rows
+// The header is taken from thead or from the leading rows of th cells,
+// several header rows are joined per column.
+//
+func (id *DOMNode) Table() (*Table, error) {
+	if id.Tag != "table" {
+		return nil, ErrNotTable
+	}
+
+	table := &Table{}
+	thead := false
+	for _, child := range id.Children {
+		switch child.Tag {
+		case "caption":
+			table.Caption = _tableCellText(child)
+		case "thead":
+			thead = true
+		}
+	}
+
+	// slots still covered by a rowspan from the rows above
+	type _pending struct {
+		node *DOMNode
+		text string
+		rows int
+	}
+	pending := map[int]*_pending{}
+	var group *DOMNode
+
+	headerRows := 0
+	inHeader := true
+	for _, row := range _tableRowNodes(id) {
+		// a rowspan ends with its row group (thead, tbody or tfoot)
+		if row.Parent != group {
+			group = row.Parent
+			pending = map[int]*_pending{}
+		}
+
+		texts := []string{}
+		nodes := []*DOMNode{}
+		fill := func() {
+			for column := len(texts); pending[column] != nil; column = len(texts) {
+				texts = append(texts, pending[column].text)
+				nodes = append(nodes, pending[column].node)
+				if pending[column].rows--; pending[column].rows == 0 {
+					delete(pending, column)
+				}
+			}
+		}
+
+		header := row.Parent.Tag == "thead"
+		allHeader := true
+		for _, cell := range row.Children {
+			if cell.Tag != "td" && cell.Tag != "th" {
+				continue
+			}
+			allHeader = allHeader && cell.Tag == "th"
+
+			fill()
+			text := _tableCellText(cell)
+			columns := _tableSpan(cell.Attr("colspan"))
+			rows := _tableRowSpan(cell.Attr("rowspan"))
+			for i := 0; i < columns; i++ {
+				// rowspan 0 counts down from -1, it never reaches 0 and ends with the row group
+				if rows != 1 {
+					pending[len(texts)] = &_pending{node: cell, text: text, rows: rows - 1}
+				}
+				texts = append(texts, text)
+				nodes = append(nodes, cell)
+			}
+		}
+		fill()
+
+		if len(nodes) == 0 {
+			continue
+		}
+
+		if inHeader && (header || allHeader) {
+			headerRows++
+		} else {
+			inHeader = false
+		}
+		table.Rows = append(table.Rows, texts)
+		table.Cells = append(table.Cells, nodes)
+	}
+
+	// a lone row of th is the column names only when data follows
+	if headerRows == len(table.Rows) && !thead {
+		headerRows = 0
+	}
+
+	// ragged rows are padded to the widest one
+	width := 0
+	for _, row := range table.Rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	for i := range table.Rows {
+		for len(table.Rows[i]) < width {
+			table.Rows[i] = append(table.Rows[i], "")
+			table.Cells[i] = append(table.Cells[i], nil)
+		}
+	}
+
+	if headerRows > 0 {
+		table.Header = make([]string, width)
+		for column := range table.Header {
+			parts := []string{}
+			for _, row := range table.Rows[:headerRows] {
+				// a spanning header cell names its columns once
+				if text := row[column]; len(text) > 0 && (len(parts) == 0 || parts[len(parts)-1] != text) {
+					parts = append(parts, text)
+				}
+			}
+			table.Header[column] = strings.Join(parts, " ")
+		}
+		table.Rows = table.Rows[headerRows:]
+		table.Cells = table.Cells[headerRows:]
+	}
+
+	return table, nil
+}
+
+//
+// Table: The colspan or rowspan value, 1 when absent or invalid
+//
+func _tableSpan(value string) int {
+	span, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || span < 1 {
+		return 1
+	}
+	if span > TABLE_MAX_SPAN {
+		return TABLE_MAX_SPAN
+	}
+
+	return span
+}
+
+//
+// Table: The rowspan value, 0 spans the rest of the row group
+//
+func _tableRowSpan(value string) int {
+	if span, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && span == 0 {
+		return 0
+	}
+
+	return _tableSpan(value)
+}
+
+//
+// Table: The collapsed text of a cell, without its nested tables
+//
+func _tableCellText(cell *DOMNode) string {
+	w := &_textWriter{}
+	for _, child := range cell.ChildNodes {
+		if child.Tag != "table" {
+			w.node(child)
+		}
+	}
+
+	return strings.Join(strings.Fields(w.String()), " ")
+}
+
+//
+// Keys : The record keys of the columns, the header text made unique
+// Columns without a header text are keyed by their position.
+//
+func (id *Table) Keys() []string {
+	width := len(id.Header)
+	if len(id.Rows) > 0 && len(id.Rows[0]) > width {
+		width = len(id.Rows[0])
+	}
+
+	keys := make([]string, width)
+	seen := map[string]int{}
+	for column := range keys {
+		key := ""
+		if column < len(id.Header) {
+			key = id.Header[column]
+		}
+		if len(key) == 0 {
+			key = "column" + strconv.Itoa(column+1)
+		}
+		if seen[key]++; seen[key] > 1 {
+			key += " " + strconv.Itoa(seen[key])
+		}
+		keys[column] = key
+	}
+
+	return keys
+}
+
+//
+// Records : The body rows keyed by the column keys
+//
+func (id *Table) Records() []map[string]string {
+	keys := id.Keys()
+	records := make([]map[string]string, 0, len(id.Rows))
+	for _, row := range id.Rows {
+		record := make(map[string]string, len(keys))
+		for column, key := range keys {
+			if column < len(row) {
+				record[key] = row[column]
+			}
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
+//
+// JSON : The records as a JSON array of objects
+//
+func (id *Table) JSON() ([]byte, error) {
+	return json.Marshal(id.Records())
+}
+
+//
+// WriteCSV : Write the header, when there is one, and the rows as CSV
+//
+func (id *Table) WriteCSV(out io.Writer) error {
+	writer := csv.NewWriter(out)
+	if len(id.Header) > 0 {
+		if err := writer.Write(id.Header); err != nil {
+			return err
+		}
+	}
+	if err := writer.WriteAll(id.Rows); err != nil {
+		return err
+	}
+
+	return writer.Error()
+}
+
+//
+// Unmarshal : Fill a slice of structs, one per body row
+// A field takes the column named by its table tag, else the column whose header
+// matches the field name ignoring case, spaces and punctuation. A table tag of
+// "-" skips the field. Strings, booleans, integers and floats are converted,
+// numbers may group their thousands with commas.
+//
+func (id *Table) Unmarshal(v interface{}) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Ptr || target.Elem().Kind() != reflect.Slice || target.Elem().Type().Elem().Kind() != reflect.Struct {
+		return ErrTableTarget
+	}
+
+	columns := map[string]int{}
+	for column, key := range id.Keys() {
+		if _, ok := columns[_tableKey(key)]; !ok {
+			columns[_tableKey(key)] = column
+		}
+	}
+
+	slice := target.Elem()
+	itemType := slice.Type().Elem()
+	for i, row := range id.Rows {
+		item := reflect.New(itemType).Elem()
+		for f := 0; f < itemType.NumField(); f++ {
+			field := itemType.Field(f)
+			name := field.Tag.Get("table")
+			if name == "-" || len(field.PkgPath) > 0 {
+				continue
+			}
+			if len(name) == 0 {
+				name = field.Name
+			}
+
+			column, ok := columns[_tableKey(name)]
+			if !ok || column >= len(row) {
+				continue
+			}
+			if err := _setFieldText(item.Field(f), row[column]); err != nil {
+				return fmt.Errorf("goweb: table row %d column %q: %v", i+1, name, err)
+			}
+		}
+		slice.Set(reflect.Append(slice, item))
+	}
+
+	return nil
+}
+
+//
+// Table: The header text reduced to lower case letters and digits
+//
+func _tableKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+//
+// Set a string, boolean or numeric field from text
+//
+func _setFieldText(field reflect.Value, text string) (err error) {
+	text = strings.TrimSpace(text)
+	if field.Kind() == reflect.Ptr {
+		if len(text) == 0 {
+			return nil
+		}
+		value := reflect.New(field.Type().Elem())
+		if err = _setFieldText(value.Elem(), text); err == nil {
+			field.Set(value)
+		}
+		return err
+	}
+
+	if field.Kind() != reflect.String && field.Kind() != reflect.Bool {
+		if len(text) == 0 {
+			return nil
+		}
+		if strings.Contains(text, ",") {
+			if !_tableGroupedNumber.MatchString(text) {
+				return errors.New("invalid digit grouping " + strconv.Quote(text))
+			}
+			text = strings.Replace(text, ",", "", -1)
+		}
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(text)
+	case reflect.Bool:
+		var value bool
+		if len(text) > 0 {
+			if value, err = strconv.ParseBool(strings.ToLower(text)); err != nil {
+				switch strings.ToLower(text) {
+				case "yes", "y", "on":
+					value, err = true, nil
+				case "no", "n", "off":
+					value, err = false, nil
+				}
+			}
+		}
+		field.SetBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var value int64
+		if value, err = strconv.ParseInt(text, 10, field.Type().Bits()); err == nil {
+			field.SetInt(value)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var value uint64
+		if value, err = strconv.ParseUint(text, 10, field.Type().Bits()); err == nil {
+			field.SetUint(value)
+		}
+	case reflect.Float32, reflect.Float64:
+		var value float64
+		if value, err = strconv.ParseFloat(text, field.Type().Bits()); err == nil {
+			field.SetFloat(value)
+		}
+	default:
+		err = errors.New("unsupported field type " + field.Type().String())
+	}
+
+	return err
+}
@@ -0,0 +1,154 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const _tableTestPage = `<html><body><table>
+<caption>River flows</caption>
+<thead>
+  <tr><th rowspan="2">Name</th><th colspan="2">Flow</th></tr>
+  <tr><th>Mean</th><th>Peak</th></tr>
+</thead>
+<tbody>
+  <tr><td rowspan="2">Yukon</td><td>6,400</td><td>30000</td></tr>
+  <tr><td>6,500</td><td>31000</td></tr>
+  <tr><td>Mackenzie <table><tr><td>nested</td></tr></table></td><td colspan="2">9,910</td></tr>
+</tbody>
+</table></body></html>`
+
+func TestTable(t *testing.T) {
+	d := NewDOM()
+	d.SetContents(_tableTestPage)
+
+	table, err := d.Find("table", nil)[0].Table()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if table.Caption != "River flows" {
+		t.Errorf("unexpected caption %q", table.Caption)
+	}
+	if !reflect.DeepEqual(table.Header, []string{"Name", "Flow Mean", "Flow Peak"}) {
+		t.Errorf("unexpected header %q", table.Header)
+	}
+	expected := [][]string{
+		{"Yukon", "6,400", "30000"},
+		{"Yukon", "6,500", "31000"},
+		{"Mackenzie", "9,910", "9,910"},
+	}
+	if !reflect.DeepEqual(table.Rows, expected) {
+		t.Errorf("unexpected rows %q", table.Rows)
+	}
+	if nested, _ := table.Cells[2][0].Children[0].Table(); nested == nil || nested.Rows[0][0] != "nested" {
+		t.Errorf("expected the nested table in the cell")
+	}
+
+	if _, err := d.RootNode().Table(); err != ErrNotTable {
+		t.Errorf("expected ErrNotTable, got %v", err)
+	}
+
+	var out strings.Builder
+	if err := table.WriteCSV(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "Name,Flow Mean,Flow Peak\nYukon,\"6,400\",30000\n") {
+		t.Errorf("unexpected CSV\n%s", out.String())
+	}
+
+	data, err := table.JSON()
+	if err != nil || !strings.HasPrefix(string(data), `[{"Flow Mean":"6,400","Flow Peak":"30000","Name":"Yukon"}`) {
+		t.Errorf("unexpected JSON %s %v", data, err)
+	}
+
+	type flow struct {
+		Name string
+		Mean int `table:"Flow Mean"`
+		Peak *uint
+		Note string `table:"-"`
+	}
+	flows := []flow{}
+	if err := table.Unmarshal(&flows); err != nil {
+		t.Fatal(err)
+	}
+	// the column is named Flow Peak, Peak matches nothing and stays nil
+	if len(flows) != 3 || flows[2].Name != "Mackenzie" || flows[2].Mean != 9910 || flows[0].Peak != nil {
+		t.Errorf("unexpected records %+v", flows)
+	}
+
+	if err := table.Unmarshal(flows); err != ErrTableTarget {
+		t.Errorf("expected ErrTableTarget, got %v", err)
+	}
+
+	type bad struct {
+		Name int
+	}
+	if err := table.Unmarshal(&[]bad{}); err == nil || !strings.Contains(err.Error(), "row 1") {
+		t.Errorf("expected a conversion error, got %v", err)
+	}
+}
+
+func TestTableHeaderless(t *testing.T) {
+	d := NewDOM()
+	d.SetContents(`<table><tr><th>a</th><td>1</td></tr><tr><th>b</th><td>2</td></tr></table>`)
+
+	table, _ := d.Find("table", nil)[0].Table()
+	if table.Header != nil || len(table.Rows) != 2 {
+		t.Errorf("unexpected table %+v", table)
+	}
+	if keys := table.Keys(); !reflect.DeepEqual(keys, []string{"column1", "column2"}) {
+		t.Errorf("unexpected keys %q", keys)
+	}
+}
+
+func TestTableRowGroups(t *testing.T) {
+	d := NewDOM()
+	d.SetContents(`<table><thead><tr><th rowspan="2">Name</th><th>Flow</th></tr></thead>` +
+		`<tbody><tr><td>Yukon</td><td>6,400</td></tr></tbody></table>`)
+
+	// the rowspan does not reach past the header into the body
+	table, _ := d.Find("table", nil)[0].Table()
+	if !reflect.DeepEqual(table.Header, []string{"Name", "Flow"}) || !reflect.DeepEqual(table.Rows, [][]string{{"Yukon", "6,400"}}) {
+		t.Errorf("unexpected table %q %q", table.Header, table.Rows)
+	}
+}
+
+func TestTableRowSpanZero(t *testing.T) {
+	d := NewDOM()
+	d.SetContents(`<table><thead><tr><th>River</th><th>Flow</th></tr></thead>` +
+		`<tbody><tr><td rowspan="0">Yukon</td><td colspan="0">1</td></tr><tr><td>2</td></tr><tr><td>3</td></tr></tbody>` +
+		`<tbody><tr><td>Mackenzie</td><td>4</td></tr></tbody></table>`)
+
+	// rowspan 0 covers the rest of its tbody and no further, colspan 0 is 1
+	table, _ := d.Find("table", nil)[0].Table()
+	expected := [][]string{{"Yukon", "1"}, {"Yukon", "2"}, {"Yukon", "3"}, {"Mackenzie", "4"}}
+	if !reflect.DeepEqual(table.Rows, expected) {
+		t.Errorf("unexpected rows %q", table.Rows)
+	}
+}
+
+func TestTableNumberGrouping(t *testing.T) {
+	var count int
+	var ratio float64
+	for text, expected := range map[string]float64{"1,234": 1234, "-12,345,678": -12345678, "999": 999} {
+		if err := _setFieldText(reflect.ValueOf(&count).Elem(), text); err != nil || float64(count) != expected {
+			t.Errorf("%s: expected %v, got %d %v", text, expected, count, err)
+		}
+	}
+	if err := _setFieldText(reflect.ValueOf(&ratio).Elem(), "1,234.5"); err != nil || ratio != 1234.5 {
+		t.Errorf("expected 1234.5, got %v %v", ratio, err)
+	}
+
+	// decimal commas and lists are not grouping separators
+	for _, text := range []string{"1,5", "1,2,3", "12,34", "1234,567", ",123"} {
+		if err := _setFieldText(reflect.ValueOf(&ratio).Elem(), text); err == nil {
+			t.Errorf("%s: expected a conversion error, got %v", text, ratio)
+		}
+	}
+}
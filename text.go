@@ -0,0 +1,582 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// block elements followed by a blank line
+	_textParagraphTags = map[string]bool{
+		"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "pre": true,
+		"blockquote": true, "ul": true, "ol": true, "table": true, "dl": true, "figure": true, "hr": true,
+		"address": true, "details": true,
+	}
+	// block elements on a line of their own
+	_textLineTags = map[string]bool{
+		"div": true, "section": true, "article": true, "main": true, "header": true, "footer": true,
+		"nav": true, "aside": true, "li": true, "tr": true, "dt": true, "dd": true, "figcaption": true,
+		"form": true, "fieldset": true, "caption": true, "summary": true, "body": true, "legend": true,
+	}
+	// angle brackets too, text would otherwise pass as inline HTML or open a quote
+	_markdownEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`, `<`, `\<`, `>`, `\>`)
+	// words that open a heading, list or setext underline when first on a line
+	_markdownBlockStart = regexp.MustCompile(`^(?:#{1,6}$|[-+=]+$|\d{1,9}[.)]$)`)
+)
+
+//
+// PlainText : The node laid out as plain text
+// Blocks are separated by line breaks, inline whitespace is collapsed, pre
+// keeps its whitespace, list items get bullets and tables aligned columns.
+//
+func (id *DOMNode) PlainText() string {
+	w := &_textWriter{}
+	w.node(id)
+
+	return w.String()
+}
+
+//
+// Markdown : The node as Markdown
+// Headings, emphasis, code, links, images, lists, block quotes and tables are converted.
+//
+func (id *DOMNode) Markdown() string {
+	w := &_textWriter{markdown: true, escape: true}
+	w.node(id)
+
+	return w.String()
+}
+
+//
+// PlainText : The document body laid out as plain text
+//
+func (id *DOM) PlainText() string {
+	if root := id.RootNode(); root != nil {
+		return root.PlainText()
+	}
+
+	return ""
+}
+
+//
+// Markdown : The document body as Markdown
+//
+func (id *DOM) Markdown() string {
+	if root := id.RootNode(); root != nil {
+		return root.Markdown()
+	}
+
+	return ""
+}
+
+//
+// accumulates text, owing line breaks and spaces until the next content
+// flat writes the trimmed text nodes space separated, as ReaderText does.
+//
+type _textWriter struct {
+	out       strings.Builder
+	flat      bool
+	markdown  bool
+	escape    bool
+	prefixes  []string
+	breaks    int
+	gap       string
+	space     bool
+	lead      bool
+	hold      bool
+	lineStart bool
+	opening   bool
+	started   bool
+	listDepth int
+}
+
+func (w *_textWriter) String() string {
+	if w.flat {
+		return w.out.String()
+	}
+
+	lines := strings.Split(w.out.String(), "\n")
+	for i, line := range lines {
+		// markdown hard breaks are two trailing spaces
+		if !w.markdown || !strings.HasSuffix(line, "  ") || strings.TrimSpace(line) == "" {
+			lines[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+		}
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+//
+// Text: A writer for inline content rendered apart, such as a link label or table cell
+//
+func (w *_textWriter) sub() *_textWriter {
+	return &_textWriter{markdown: w.markdown, escape: w.escape}
+}
+
+func (w *_textWriter) prefix() string {
+	return strings.Join(w.prefixes, "")
+}
+
+//
+// Text: Owe count line breaks before the next content
+//
+func (w *_textWriter) block(count int) {
+	if w.hold || !w.started {
+		return
+	}
+	// blank lines only carry the prefix shared by the blocks on both sides
+	if gap := strings.TrimRight(w.prefix(), " "); w.breaks == 0 || len(gap) < len(w.gap) {
+		w.gap = gap
+	}
+	if count > w.breaks {
+		w.breaks = count
+	}
+	w.space = false
+}
+
+//
+// Text: Write content, settling the owed breaks, prefixes and space first
+//
+func (w *_textWriter) write(s string) {
+	if len(s) == 0 {
+		return
+	}
+
+	if w.breaks > 0 {
+		for i := 0; i < w.breaks; i++ {
+			if i > 0 {
+				w.out.WriteString(w.gap)
+			}
+			w.out.WriteString("\n")
+		}
+		w.breaks = 0
+		w.lineStart = true
+	}
+
+	if w.lineStart {
+		w.out.WriteString(w.prefix())
+		w.lineStart = false
+	} else if w.space {
+		w.out.WriteString(" ")
+	}
+
+	w.out.WriteString(strings.Replace(s, "\n", "\n"+w.prefix(), -1))
+	w.space = false
+	w.hold = false
+	w.opening = false
+	w.started = true
+}
+
+//
+// Text: Write a text node, collapsing its whitespace
+//
+func (w *_textWriter) text(s string) {
+	if len(s) == 0 {
+		return
+	}
+
+	first, _ := utf8.DecodeRuneInString(s)
+	if unicode.IsSpace(first) {
+		if !w.started {
+			w.lead = true
+		}
+		w.space = true
+	}
+
+	for i, word := range strings.Fields(s) {
+		if i > 0 {
+			w.space = true
+		}
+		if w.escape {
+			word = _markdownEscaper.Replace(word)
+			if (!w.started || w.breaks > 0 || w.lineStart || w.opening) && _markdownBlockStart.MatchString(word) {
+				word = _markdownEscapeBlock(word)
+			}
+		}
+		w.write(word)
+	}
+
+	last, _ := utf8.DecodeLastRuneInString(s)
+	if unicode.IsSpace(last) {
+		w.space = true
+	}
+}
+
+//
+// Text: A hard line break
+//
+func (w *_textWriter) lineBreak() {
+	if !w.started {
+		return
+	}
+	if w.markdown && w.breaks == 0 {
+		w.out.WriteString("  ")
+	}
+	if w.breaks == 0 {
+		w.gap = strings.TrimRight(w.prefix(), " ")
+	}
+	w.breaks++
+	w.space = false
+}
+
+func (w *_textWriter) children(node *DOMNode) {
+	for _, child := range node.ChildNodes {
+		w.node(child)
+	}
+}
+
+//
+// Text: Render a node and its subtree
+//
+func (w *_textWriter) node(node *DOMNode) {
+	if node.IsText() {
+		if w.flat {
+			w.space = w.started
+			w.write(strings.TrimSpace(node.Data))
+		} else {
+			w.text(node.Data)
+		}
+		return
+	}
+	if node.Tag == DOM_DOCUMENT_TAG {
+		w.children(node)
+		return
+	}
+	if !node.IsElement() || _domNonReaderTags[node.Tag] || (node.Tag == "head" && !w.flat) {
+		return
+	}
+	if w.flat {
+		// one line of text, without layout or markup
+		w.children(node)
+		return
+	}
+
+	switch node.Tag {
+	case "br":
+		w.lineBreak()
+	case "hr":
+		w.block(2)
+		if w.markdown {
+			w.write("---")
+		} else {
+			w.write("----------")
+		}
+		w.block(2)
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		w.block(2)
+		if w.markdown {
+			w.write(strings.Repeat("#", int(node.Tag[1]-'0')))
+			w.space = true
+			w.hold = true
+		}
+		w.children(node)
+		w.block(2)
+	case "pre":
+		w.block(2)
+		text := strings.TrimRight(_preText(node), "\n")
+		if w.markdown {
+			language := ""
+			if codes := node.Children; len(codes) == 1 && codes[0].Tag == "code" {
+				language = strings.TrimPrefix(codes[0].Attr("class"), "language-")
+				if strings.ContainsAny(language, " =") {
+					language = ""
+				}
+			}
+			fence := _markdownFence(text)
+			text = fence + language + "\n" + text + "\n" + fence
+		}
+		w.write(text)
+		w.block(2)
+	case "blockquote":
+		w.block(2)
+		if w.markdown {
+			w.prefixes = append(w.prefixes, "> ")
+		} else {
+			w.prefixes = append(w.prefixes, "  ")
+		}
+		w.children(node)
+		w.prefixes = w.prefixes[:len(w.prefixes)-1]
+		w.block(2)
+	case "ul", "ol":
+		w.list(node)
+	case "li":
+		// an item outside of a list
+		w.item(node, "- ")
+	case "table":
+		w.block(2)
+		w.table(node)
+		w.block(2)
+	case "img":
+		alt := strings.TrimSpace(node.Attr("alt"))
+		if w.markdown && len(node.Attr("src")) > 0 {
+			w.write("![" + _markdownEscaper.Replace(alt) + "](" + _markdownURL(node.Attr("src")) + ")")
+		} else if len(alt) > 0 {
+			w.write(alt)
+		}
+	case "a":
+		if href := node.Attr("href"); w.markdown && len(href) > 0 && !strings.HasPrefix(strings.ToLower(href), "javascript:") {
+			w.wrap(node, "[", "]("+_markdownURL(href)+")", true)
+		} else {
+			w.children(node)
+		}
+	case "strong", "b":
+		w.wrap(node, "**", "**", w.markdown)
+	case "em", "i":
+		w.wrap(node, "*", "*", w.markdown)
+	case "del", "s", "strike":
+		w.wrap(node, "~~", "~~", w.markdown)
+	case "code", "kbd", "samp", "tt":
+		if w.markdown {
+			code := strings.Join(strings.Fields(node.ReaderText()), " ")
+			if len(code) > 0 {
+				fence := "`"
+				if strings.Contains(code, "`") {
+					fence = "``"
+				}
+				w.write(fence + code + fence)
+			}
+		} else {
+			w.children(node)
+		}
+	default:
+		breaks := 0
+		if _textParagraphTags[node.Tag] {
+			breaks = 2
+		} else if _textLineTags[node.Tag] {
+			breaks = 1
+		}
+		w.block(breaks)
+		w.children(node)
+		w.block(breaks)
+	}
+}
+
+//
+// Text: Inline content between markers, the surrounding whitespace kept outside of them
+//
+func (w *_textWriter) wrap(node *DOMNode, open string, close string, marked bool) {
+	if !marked {
+		w.children(node)
+		return
+	}
+
+	inner := w.sub()
+	inner.children(node)
+	text := inner.String()
+	if len(text) == 0 {
+		return
+	}
+
+	if inner.lead {
+		w.space = true
+	}
+	w.write(open + text + close)
+	if inner.space {
+		w.space = true
+	}
+}
+
+//
+// Text: List items with bullets or numbers, nested lists indented below their item
+//
+func (w *_textWriter) list(node *DOMNode) {
+	if w.listDepth > 0 {
+		w.block(1)
+	} else {
+		w.block(2)
+	}
+
+	number := 1
+	if start, err := strconv.Atoi(node.Attr("start")); err == nil {
+		number = start
+	}
+
+	w.listDepth++
+	for _, child := range node.ChildNodes {
+		if child.Tag != "li" {
+			w.node(child)
+			continue
+		}
+
+		bullet := "- "
+		if node.Tag == "ol" {
+			bullet = strconv.Itoa(number) + ". "
+			number++
+		}
+		w.item(child, bullet)
+	}
+	w.listDepth--
+
+	if w.listDepth > 0 {
+		w.block(1)
+	} else {
+		w.block(2)
+	}
+}
+
+func (w *_textWriter) item(node *DOMNode, bullet string) {
+	w.block(1)
+	w.write(strings.TrimSpace(bullet))
+	w.space = true
+	w.hold = true
+	// the item content may open a block of its own
+	w.opening = true
+
+	w.prefixes = append(w.prefixes, strings.Repeat(" ", len(bullet)))
+	w.children(node)
+	w.prefixes = w.prefixes[:len(w.prefixes)-1]
+	w.hold = false
+	w.block(1)
+}
+
+//
+// Text: A table as a Markdown pipe table or as aligned plain text columns
+//
+func (w *_textWriter) table(node *DOMNode) {
+	rows := [][]string{}
+	columns := 0
+	for _, row := range _tableRowNodes(node) {
+		cells := []string{}
+		for _, cell := range row.Children {
+			if cell.Tag != "td" && cell.Tag != "th" {
+				continue
+			}
+			inner := w.sub()
+			inner.children(cell)
+			text := strings.Join(strings.Fields(inner.String()), " ")
+			if w.markdown {
+				text = strings.Replace(text, "|", `\|`, -1)
+			}
+			cells = append(cells, text)
+		}
+		if len(cells) > columns {
+			columns = len(cells)
+		}
+		rows = append(rows, cells)
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	widths := make([]int, columns)
+	for i := range rows {
+		for len(rows[i]) < columns {
+			rows[i] = append(rows[i], "")
+		}
+		for j, cell := range rows[i] {
+			if length := utf8.RuneCountInString(cell); length > widths[j] {
+				widths[j] = length
+			}
+		}
+	}
+
+	lines := []string{}
+	for i, row := range rows {
+		padded := make([]string, columns)
+		for j, cell := range row {
+			padded[j] = cell + strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell))
+		}
+
+		if !w.markdown {
+			lines = append(lines, strings.TrimRight(strings.Join(padded, "  "), " "))
+			continue
+		}
+
+		lines = append(lines, "| "+strings.Join(padded, " | ")+" |")
+		if i == 0 {
+			// the first row is the header, as pipe tables require one
+			separators := make([]string, columns)
+			for j := range separators {
+				width := widths[j]
+				if width < 3 {
+					width = 3
+				}
+				separators[j] = strings.Repeat("-", width)
+			}
+			lines = append(lines, "| "+strings.Join(separators, " | ")+" |")
+		}
+	}
+
+	w.write(strings.Join(lines, "\n"))
+}
+
+//
+// Text: The rows of a table, through its sections but not into nested tables
+//
+func _tableRowNodes(table *DOMNode) (rows []*DOMNode) {
+	for _, child := range table.Children {
+		switch child.Tag {
+		case "tr":
+			rows = append(rows, child)
+		case "thead", "tbody", "tfoot":
+			for _, row := range child.Children {
+				if row.Tag == "tr" {
+					rows = append(rows, row)
+				}
+			}
+		}
+	}
+
+	return rows
+}
+
+//
+// Text: The text of a pre element with its whitespace intact
+//
+func _preText(node *DOMNode) (result string) {
+	for _, child := range node.ChildNodes {
+		if child.IsText() {
+			result += child.Data
+		} else if child.Tag == "br" {
+			result += "\n"
+		} else if child.IsElement() {
+			result += _preText(child)
+		}
+	}
+
+	return result
+}
+
+//
+// Text: Escape the marker a word would open a Markdown block with
+//
+func _markdownEscapeBlock(word string) string {
+	if last := len(word) - 1; word[0] >= '0' && word[0] <= '9' {
+		return word[:last] + `\` + word[last:]
+	}
+
+	return `\` + word
+}
+
+//
+// Text: A code fence longer than any backtick run of the content
+//
+func _markdownFence(text string) string {
+	longest, run := 0, 0
+	for i := 0; i < len(text); i++ {
+		if text[i] == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	if longest < 3 {
+		return "```"
+	}
+
+	return strings.Repeat("`", longest+1)
+}
+
+func _markdownURL(href string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(strings.TrimSpace(href))
+}
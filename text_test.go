@@ -0,0 +1,83 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"testing"
+)
+
+const _textTestPage = `<html><head><title>Ignored</title></head><body>
+<h2>Notes  on <em>rivers</em></h2>
+<p>Rivers   carry <strong>water</strong> and <a href="/silt (fine)">silt</a>.<br>New line_here</p>
+<ul>
+  <li>One</li>
+  <li>Two
+    <ol start="3"><li>Three</li><li>Four</li></ol>
+  </li>
+</ul>
+<pre><code class="language-go">if a {
+    b()
+}</code></pre>
+<blockquote><p>Quoted</p><p>Twice</p></blockquote>
+<table>
+  <thead><tr><th>Name</th><th>Flow</th></tr></thead>
+  <tbody><tr><td>Yukon</td><td>6400</td></tr><tr><td>Mackenzie</td><td>9|10</td></tr></tbody>
+</table>
+<script>ignored()</script>
+</body></html>`
+
+func TestPlainText(t *testing.T) {
+	d := NewDOM()
+	d.SetContents(_textTestPage)
+
+	expected := "Notes on rivers\n\n" +
+		"Rivers carry water and silt.\nNew line_here\n\n" +
+		"- One\n- Two\n  3. Three\n  4. Four\n\n" +
+		"if a {\n    b()\n}\n\n" +
+		"  Quoted\n\n  Twice\n\n" +
+		"Name       Flow\nYukon      6400\nMackenzie  9|10"
+	if text := d.PlainText(); text != expected {
+		t.Errorf("unexpected text\n%s", text)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	d := NewDOM()
+	d.SetContents(_textTestPage)
+
+	expected := "## Notes on *rivers*\n\n" +
+		"Rivers carry **water** and [silt](/silt%20%28fine%29).  \nNew line\\_here\n\n" +
+		"- One\n- Two\n  3. Three\n  4. Four\n\n" +
+		"```go\nif a {\n    b()\n}\n```\n\n" +
+		"> Quoted\n>\n> Twice\n\n" +
+		"| Name      | Flow  |\n| --------- | ----- |\n| Yukon     | 6400  |\n| Mackenzie | 9\\|10 |"
+	if markdown := d.Markdown(); markdown != expected {
+		t.Errorf("unexpected markdown\n%s", markdown)
+	}
+}
+
+func TestMarkdownBlockEscape(t *testing.T) {
+	d := NewDOM()
+	d.SetContents(`<html><body><p># not a heading</p><p>- not a list</p><p>12. not ordered</p><p>&gt;not quoted</p><p>---</p><p>a - b # c 1. d<br>+ e</p><ul><li>- item</li></ul></body></html>`)
+
+	expected := "\\# not a heading\n\n\\- not a list\n\n12\\. not ordered\n\n\\>not quoted\n\n\\---\n\n" +
+		"a - b # c 1. d  \n\\+ e\n\n- \\- item"
+	if markdown := d.Markdown(); markdown != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, markdown)
+	}
+	if text := d.PlainText(); text != "# not a heading\n\n- not a list\n\n12. not ordered\n\n>not quoted\n\n---\n\na - b # c 1. d\n+ e\n\n- - item" {
+		t.Errorf("unexpected plain text\n%s", text)
+	}
+}
+
+func TestMarkdownAngleBracketsAndFence(t *testing.T) {
+	d := NewDOM()
+	d.SetContents("<html><body><p>&lt;script&gt;alert(1)&lt;/script&gt; if a &gt; b</p><pre>```\ncode\n```</pre></body></html>")
+
+	expected := "\\<script\\>alert(1)\\</script\\> if a \\> b\n\n````\n```\ncode\n```\n````"
+	if markdown := d.Markdown(); markdown != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, markdown)
+	}
+}
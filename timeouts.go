@@ -0,0 +1,206 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	HTTP_DEFAULT_TIMEOUT = 30 * time.Second
+	// HTTP_THROUGHPUT_WINDOW is the span over which MinThroughput is measured by default
+	HTTP_THROUGHPUT_WINDOW = 5 * time.Second
+	// TIMEOUT_DISABLED lifts the Total deadline, which otherwise defaults when zero
+	TIMEOUT_DISABLED time.Duration = -1
+)
+
+var (
+	// ErrIdleTimeout is returned when the response body stalls longer than IdleRead
+	ErrIdleTimeout = errors.New("goweb: response body idle timeout")
+	// ErrSlowBody is returned when the response body drops below MinThroughput
+	ErrSlowBody = errors.New("goweb: response body below minimum throughput")
+)
+
+//
+// Timeouts def
+// Each deadline is disabled when zero, except Total which falls back to
+// HTTP_DEFAULT_TIMEOUT and is only lifted by TIMEOUT_DISABLED. Connect bounds
+// the dial, TLS the handshake, Header the wait for the response headers once
+// the request is written, IdleRead the gap between body reads and Total the
+// whole exchange.
+// MinThroughput (bytes per second) aborts a body trickling slower than that
+// over ThroughputWindow.
+//
+type Timeouts struct {
+	Connect          time.Duration
+	TLS              time.Duration
+	Header           time.Duration
+	IdleRead         time.Duration
+	Total            time.Duration
+	MinThroughput    int64
+	ThroughputWindow time.Duration
+}
+
+//
+// NewTimeouts constructor, the defaults keep the historical 30 second total
+//
+func NewTimeouts() *Timeouts {
+	return &Timeouts{Total: HTTP_DEFAULT_TIMEOUT}
+}
+
+//
+// Timeouts: The configured timeouts, otherwise the defaults
+//
+func (id *HTTP) timeouts() *Timeouts {
+	if id.Timeouts != nil {
+		return id.Timeouts
+	}
+
+	return NewTimeouts()
+}
+
+//
+// Timeouts: The deadline for the whole exchange, zero when disabled
+//
+func (id *Timeouts) total() time.Duration {
+	switch {
+	case id.Total == TIMEOUT_DISABLED:
+		return 0
+	case id.Total <= 0:
+		return HTTP_DEFAULT_TIMEOUT
+	}
+
+	return id.Total
+}
+
+//
+// WithTimeouts : A copy of the HTTP for a single call with its own timeouts
+// The copy shares the cookies and configuration of the original.
+//
+func (id *HTTP) WithTimeouts(timeouts *Timeouts) *HTTP {
+	h := *id
+	h.Timeouts = timeouts
+
+	return &h
+}
+
+//
+// Timeouts: Execute the request, watching the response body for stalls and slow throughput
+//
+func (id *HTTP) doWithTimeouts(client *http.Client, req *http.Request) (resp *http.Response, err error) {
+	timeouts := id.timeouts()
+	if timeouts.IdleRead <= 0 && timeouts.MinThroughput <= 0 {
+		return client.Do(req)
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	resp, err = client.Do(req.WithContext(ctx))
+	if resp == nil {
+		cancel()
+		return resp, err
+	}
+
+	resp.Body = _newTimeoutBody(resp.Body, cancel, timeouts)
+
+	return resp, err
+}
+
+//
+// a response body canceling its request once idle or too slow
+//
+type _timeoutBody struct {
+	body        io.ReadCloser
+	cancel      context.CancelFunc
+	idle        time.Duration
+	minRate     int64
+	window      time.Duration
+	lock        sync.Mutex
+	timer       *time.Timer
+	windowStart time.Time
+	windowBytes int64
+	err         error
+}
+
+func _newTimeoutBody(body io.ReadCloser, cancel context.CancelFunc, timeouts *Timeouts) *_timeoutBody {
+	id := &_timeoutBody{
+		body:        body,
+		cancel:      cancel,
+		idle:        timeouts.IdleRead,
+		minRate:     timeouts.MinThroughput,
+		window:      timeouts.ThroughputWindow,
+		windowStart: time.Now(),
+	}
+	if id.window <= 0 {
+		id.window = HTTP_THROUGHPUT_WINDOW
+	}
+
+	// a body stalled for a whole window is also too slow, so one watchdog covers both
+	expired := ErrIdleTimeout
+	if id.idle <= 0 || (id.minRate > 0 && id.window < id.idle) {
+		id.idle = id.window
+		expired = ErrSlowBody
+	}
+	id.timer = time.AfterFunc(id.idle, func() {
+		id.fail(expired)
+	})
+
+	return id
+}
+
+func (id *_timeoutBody) fail(err error) {
+	id.lock.Lock()
+	if id.err == nil {
+		id.err = err
+	}
+	id.lock.Unlock()
+
+	id.cancel()
+}
+
+func (id *_timeoutBody) Read(p []byte) (n int, err error) {
+	n, err = id.body.Read(p)
+
+	id.lock.Lock()
+	defer id.lock.Unlock()
+
+	if id.err != nil {
+		return n, id.err
+	}
+	if err != nil {
+		id.timer.Stop()
+		return n, err
+	}
+
+	id.timer.Reset(id.idle)
+
+	if id.minRate > 0 {
+		id.windowBytes += int64(n)
+		elapsed := time.Since(id.windowStart)
+		if elapsed >= id.window {
+			if float64(id.windowBytes)/elapsed.Seconds() < float64(id.minRate) {
+				id.err = ErrSlowBody
+				id.cancel()
+				return n, id.err
+			}
+			id.windowStart = time.Now()
+			id.windowBytes = 0
+		}
+	}
+
+	return n, nil
+}
+
+func (id *_timeoutBody) Close() error {
+	id.timer.Stop()
+	err := id.body.Close()
+	id.cancel()
+
+	return err
+}
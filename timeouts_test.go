@@ -0,0 +1,105 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newSlowTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow-header", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.Write([]byte("late"))
+	})
+	mux.HandleFunc("/stall", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("start"))
+		w.(http.Flusher).Flush()
+		time.Sleep(300 * time.Millisecond)
+		w.Write([]byte("end"))
+	})
+	mux.HandleFunc("/trickle", func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 10; i++ {
+			w.Write([]byte("."))
+			w.(http.Flusher).Flush()
+			time.Sleep(30 * time.Millisecond)
+		}
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestTimeouts(t *testing.T) {
+	server := newSlowTestServer()
+	defer server.Close()
+
+	x := NewHTTP()
+	if x.timeouts().Total != HTTP_DEFAULT_TIMEOUT {
+		t.Errorf("expected default total %s, got %s", HTTP_DEFAULT_TIMEOUT, x.timeouts().Total)
+	}
+
+	cases := []struct {
+		path     string
+		timeouts *Timeouts
+		expected string
+	}{
+		{"/slow-header", &Timeouts{Header: 50 * time.Millisecond}, "timeout awaiting response headers"},
+		{"/slow-header", &Timeouts{Total: 50 * time.Millisecond}, "Client.Timeout"},
+		{"/stall", &Timeouts{IdleRead: 50 * time.Millisecond}, ErrIdleTimeout.Error()},
+		{"/trickle", &Timeouts{MinThroughput: 1000, ThroughputWindow: 100 * time.Millisecond}, ErrSlowBody.Error()},
+		{"/stall", &Timeouts{IdleRead: time.Second, Total: time.Second}, ""},
+	}
+
+	for _, c := range cases {
+		h := x.WithTimeouts(c.timeouts)
+		contents := h.Get(server.URL + c.path)
+		err := h.LastError()
+		if len(c.expected) == 0 {
+			if err != nil || contents != "startend" {
+				t.Errorf("%s: expected success, got %v %s", c.path, err, contents)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.expected) {
+			t.Errorf("%s: expected error containing %q, got %v", c.path, c.expected, err)
+		}
+	}
+
+	// an unset total keeps the default, disabling it is explicit
+	if (&Timeouts{Header: time.Second}).total() != HTTP_DEFAULT_TIMEOUT || (&Timeouts{Total: TIMEOUT_DISABLED}).total() != 0 {
+		t.Errorf("unexpected total deadlines")
+	}
+	h := x.WithTimeouts(&Timeouts{Total: TIMEOUT_DISABLED})
+	if contents := h.Get(server.URL + "/slow-header"); contents != "late" {
+		t.Errorf("expected success without a total deadline, got %v %s", h.LastError(), contents)
+	}
+
+	// the per call copy leaves the original untouched
+	if x.Timeouts != nil {
+		t.Errorf("expected the original timeouts to be unset")
+	}
+}
+
+func TestSessionRequestTimeouts(t *testing.T) {
+	server := newSlowTestServer()
+	defer server.Close()
+
+	s := NewSession()
+	s.Timeouts = &Timeouts{Header: time.Second}
+
+	req := NewRequest(HTTP_GET, server.URL+"/slow-header")
+	req.Timeouts = &Timeouts{Header: 50 * time.Millisecond}
+	if _, err := s.Do(req); err == nil {
+		t.Errorf("expected the request timeout to apply")
+	}
+
+	if resp, err := s.Get(server.URL + "/slow-header"); err != nil || resp.Contents() != "late" {
+		t.Errorf("expected the session timeout to apply, got %v", err)
+	}
+}
@@ -0,0 +1,437 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TIMING_PHASE_DNS      = "dns"
+	TIMING_PHASE_CONNECT  = "connect"
+	TIMING_PHASE_TLS      = "tls"
+	TIMING_PHASE_TTFB     = "ttfb"
+	TIMING_PHASE_BODY     = "body"
+	TIMING_PHASE_REDIRECT = "redirect"
+	TIMING_PHASE_TOTAL    = "total"
+)
+
+// TIMING_DEFAULT_BUCKETS are the Prometheus client default histogram buckets in seconds
+var TIMING_DEFAULT_BUCKETS = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+//
+// RequestTiming def
+// The breakdown of the final exchange of a request. Phases that did not occur,
+// such as DNS on a reused connection, are zero. Redirects and RedirectTime
+// cover the hops followed before the final exchange.
+//
+type RequestTiming struct {
+	Method           string
+	URL              string
+	StatusCode       int
+	Start            time.Time
+	DNS              time.Duration
+	Connect          time.Duration
+	TLSHandshake     time.Duration
+	TimeToFirstByte  time.Duration
+	BodyRead         time.Duration
+	Total            time.Duration
+	Redirects        int
+	RedirectTime     time.Duration
+	ConnectionReused bool
+	Cached           bool
+	Err              error
+
+	traceparent string
+	events      []TimingSpanEvent
+}
+
+//
+// TimingHook receives the timing of every completed request
+//
+type TimingHook interface {
+	ObserveTiming(timing *RequestTiming)
+}
+
+//
+// TimingHookFunc adapts a function to a TimingHook
+//
+type TimingHookFunc func(timing *RequestTiming)
+
+func (id TimingHookFunc) ObserveTiming(timing *RequestTiming) {
+	id(timing)
+}
+
+//
+// the httptrace callbacks may run on transport goroutines
+//
+type _timingTrace struct {
+	lock         sync.Mutex
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+	reused       bool
+	events       []TimingSpanEvent
+}
+
+func (id *_timingTrace) mark(name string, at *time.Time) {
+	now := time.Now()
+	id.lock.Lock()
+	if at != nil {
+		*at = now
+	}
+	id.events = append(id.events, TimingSpanEvent{Name: name, Time: now})
+	id.lock.Unlock()
+}
+
+//
+// Timing : The timing of the last request, nil before the first one
+//
+func (id *HTTP) Timing() *RequestTiming {
+	return id.timing
+}
+
+//
+// Timing: Start timing the prepared request and attach the trace
+//
+func (id *HTTP) startTiming() {
+	trace := &_timingTrace{}
+	id.trace = trace
+	id.timing = &RequestTiming{Method: id.Method, URL: id.URLString(), Start: time.Now()}
+
+	clientTrace := &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			trace.mark("dns.start", &trace.dnsStart)
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			trace.mark("dns.done", &trace.dnsDone)
+		},
+		ConnectStart: func(network string, addr string) {
+			trace.lock.Lock()
+			started := !trace.connectStart.IsZero()
+			trace.lock.Unlock()
+			// dual stack dialing may start several attempts, the first one counts
+			if !started {
+				trace.mark("connect.start", &trace.connectStart)
+			}
+		},
+		ConnectDone: func(network string, addr string, err error) {
+			if err == nil {
+				trace.mark("connect.done", &trace.connectDone)
+			}
+		},
+		TLSHandshakeStart: func() {
+			trace.mark("tls.start", &trace.tlsStart)
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			trace.mark("tls.done", &trace.tlsDone)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			trace.lock.Lock()
+			trace.reused = info.Reused
+			trace.lock.Unlock()
+			trace.mark("conn.acquired", nil)
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			trace.mark("request.written", nil)
+		},
+		GotFirstResponseByte: func() {
+			trace.mark("response.first_byte", &trace.firstByte)
+		},
+	}
+
+	id.timing.traceparent = id.req.Header.Get("traceparent")
+	id.req = id.req.WithContext(httptrace.WithClientTrace(id.req.Context(), clientTrace))
+}
+
+//
+// Timing: Close the timing of the exchange
+//
+func (id *HTTP) finishTiming(err error) {
+	timing := id.timing
+	if timing == nil {
+		return
+	}
+
+	timing.Total = time.Since(timing.Start)
+	timing.Err = err
+	if id.resp != nil {
+		timing.StatusCode = id.resp.StatusCode
+	}
+
+	trace := id.trace
+	trace.lock.Lock()
+	defer trace.lock.Unlock()
+
+	timing.DNS = _timingSpan(trace.dnsStart, trace.dnsDone)
+	timing.Connect = _timingSpan(trace.connectStart, trace.connectDone)
+	timing.TLSHandshake = _timingSpan(trace.tlsStart, trace.tlsDone)
+	timing.TimeToFirstByte = _timingSpan(timing.Start, trace.firstByte)
+	timing.ConnectionReused = trace.reused
+	timing.events = append([]TimingSpanEvent(nil), trace.events...)
+}
+
+//
+// Timing: Report the timing of the request to the hooks
+//
+func (id *HTTP) observeTiming() {
+	if id.timing == nil {
+		return
+	}
+
+	for _, hook := range id.TimingHooks {
+		hook.ObserveTiming(id.timing)
+	}
+}
+
+func _timingSpan(start time.Time, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+
+	return end.Sub(start)
+}
+
+//
+// Phases : The phase durations keyed by TIMING_PHASE_*, omitting phases that did not occur
+//
+func (id *RequestTiming) Phases() map[string]time.Duration {
+	phases := map[string]time.Duration{TIMING_PHASE_TOTAL: id.Total}
+
+	for phase, duration := range map[string]time.Duration{
+		TIMING_PHASE_DNS:      id.DNS,
+		TIMING_PHASE_CONNECT:  id.Connect,
+		TIMING_PHASE_TLS:      id.TLSHandshake,
+		TIMING_PHASE_TTFB:     id.TimeToFirstByte,
+		TIMING_PHASE_BODY:     id.BodyRead,
+		TIMING_PHASE_REDIRECT: id.RedirectTime,
+	} {
+		if duration > 0 {
+			phases[phase] = duration
+		}
+	}
+
+	return phases
+}
+
+//
+// TimingMetrics def
+// A TimingHook aggregating Prometheus style histograms per phase and counters
+// per status code, exposed in the Prometheus text format by WritePrometheus
+// or by serving it as an http.Handler.
+//
+type TimingMetrics struct {
+	Namespace string
+	Buckets   []float64
+
+	lock     sync.Mutex
+	counts   map[string][]uint64
+	sums     map[string]float64
+	totals   map[string]uint64
+	requests map[string]uint64
+	errors   uint64
+}
+
+//
+// NewTimingMetrics constructor, no buckets uses TIMING_DEFAULT_BUCKETS
+//
+func NewTimingMetrics(buckets ...float64) *TimingMetrics {
+	if len(buckets) == 0 {
+		buckets = TIMING_DEFAULT_BUCKETS
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &TimingMetrics{
+		Namespace: "goweb",
+		Buckets:   buckets,
+		counts:    map[string][]uint64{},
+		sums:      map[string]float64{},
+		totals:    map[string]uint64{},
+		requests:  map[string]uint64{},
+	}
+}
+
+func (id *TimingMetrics) ObserveTiming(timing *RequestTiming) {
+	id.lock.Lock()
+	defer id.lock.Unlock()
+
+	if timing.Err != nil {
+		id.errors++
+		return
+	}
+	id.requests[strconv.Itoa(timing.StatusCode)]++
+
+	for phase, duration := range timing.Phases() {
+		counts, ok := id.counts[phase]
+		if !ok {
+			counts = make([]uint64, len(id.Buckets))
+			id.counts[phase] = counts
+		}
+
+		seconds := duration.Seconds()
+		for i, bound := range id.Buckets {
+			if seconds <= bound {
+				counts[i]++
+			}
+		}
+		id.sums[phase] += seconds
+		id.totals[phase]++
+	}
+}
+
+//
+// WritePrometheus : Write the metrics in the Prometheus text exposition format
+//
+func (id *TimingMetrics) WritePrometheus(w io.Writer) error {
+	id.lock.Lock()
+	defer id.lock.Unlock()
+
+	var out strings.Builder
+
+	name := id.Namespace + "_request_duration_seconds"
+	out.WriteString("# HELP " + name + " HTTP request phase durations.\n")
+	out.WriteString("# TYPE " + name + " histogram\n")
+	for _, phase := range _sortedKeys(id.totals) {
+		for i, bound := range id.Buckets {
+			fmt.Fprintf(&out, "%s_bucket{phase=%q,le=%q} %d\n", name, phase, strconv.FormatFloat(bound, 'g', -1, 64), id.counts[phase][i])
+		}
+		fmt.Fprintf(&out, "%s_bucket{phase=%q,le=\"+Inf\"} %d\n", name, phase, id.totals[phase])
+		fmt.Fprintf(&out, "%s_sum{phase=%q} %g\n", name, phase, id.sums[phase])
+		fmt.Fprintf(&out, "%s_count{phase=%q} %d\n", name, phase, id.totals[phase])
+	}
+
+	name = id.Namespace + "_requests_total"
+	out.WriteString("# HELP " + name + " HTTP requests by status code.\n")
+	out.WriteString("# TYPE " + name + " counter\n")
+	for _, code := range _sortedKeys(id.requests) {
+		fmt.Fprintf(&out, "%s{code=%q} %d\n", name, code, id.requests[code])
+	}
+
+	name = id.Namespace + "_request_errors_total"
+	out.WriteString("# HELP " + name + " HTTP requests that failed without a response.\n")
+	out.WriteString("# TYPE " + name + " counter\n")
+	fmt.Fprintf(&out, "%s %d\n", name, id.errors)
+
+	_, err := io.WriteString(w, out.String())
+
+	return err
+}
+
+//
+// ServeHTTP : Serve the metrics for a Prometheus scrape
+//
+func (id *TimingMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	id.WritePrometheus(w)
+}
+
+func _sortedKeys(m map[string]uint64) (result []string) {
+	for key := range m {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+//
+// TimingSpan def
+// A client span shaped after the OpenTelemetry data model so it can be handed
+// to any tracing SDK, attribute keys follow the HTTP semantic conventions.
+//
+type TimingSpan struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Name         string
+	Kind         string
+	StartTime    time.Time
+	EndTime      time.Time
+	Attributes   map[string]interface{}
+	Events       []TimingSpanEvent
+	Error        string
+}
+
+//
+// TimingSpanEvent def
+//
+type TimingSpanEvent struct {
+	Name string
+	Time time.Time
+}
+
+//
+// Span : The timing as a client span, continuing any W3C traceparent request header
+//
+func (id *RequestTiming) Span() *TimingSpan {
+	span := &TimingSpan{
+		TraceID:   _randomHex(16),
+		SpanID:    _randomHex(8),
+		Name:      "HTTP " + id.Method,
+		Kind:      "client",
+		StartTime: id.Start,
+		EndTime:   id.Start.Add(id.Total),
+		Events:    append([]TimingSpanEvent(nil), id.events...),
+		Attributes: map[string]interface{}{
+			"http.request.method": id.Method,
+			"url.full":            id.URL,
+		},
+	}
+
+	// version-traceid-parentid-flags
+	if parts := strings.Split(id.traceparent, "-"); len(parts) == 4 && len(parts[1]) == 32 && len(parts[2]) == 16 {
+		span.TraceID = parts[1]
+		span.ParentSpanID = parts[2]
+	}
+
+	if id.StatusCode > 0 {
+		span.Attributes["http.response.status_code"] = id.StatusCode
+	}
+	if id.Redirects > 0 {
+		span.Attributes["http.request.resend_count"] = id.Redirects
+	}
+	if id.Cached {
+		span.Attributes["goweb.cache_hit"] = true
+	}
+	if id.Err != nil {
+		span.Error = id.Err.Error()
+	} else if id.StatusCode >= 500 {
+		span.Error = http.StatusText(id.StatusCode)
+	}
+
+	return span
+}
+
+//
+// SpanExporter adapts a span consumer to a TimingHook
+//
+type SpanExporter func(span *TimingSpan)
+
+func (id SpanExporter) ObserveTiming(timing *RequestTiming) {
+	id(timing.Span())
+}
+
+func _randomHex(size int) string {
+	buffer := make([]byte, size)
+	rand.Read(buffer)
+
+	return hex.EncodeToString(buffer)
+}
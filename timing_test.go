@@ -0,0 +1,80 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRequestTiming(t *testing.T) {
+	server := newEchoTestServer()
+	defer server.Close()
+
+	metrics := NewTimingMetrics()
+	spans := []*TimingSpan{}
+
+	x := NewHTTP()
+	x.Header = http.Header{}
+	x.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	x.TimingHooks = []TimingHook{metrics, SpanExporter(func(span *TimingSpan) {
+		spans = append(spans, span)
+	})}
+
+	x.Post(server.URL+"/see-other", map[string]string{"a": "1"})
+
+	timing := x.Timing()
+	if timing == nil || timing.StatusCode != 200 || !strings.HasSuffix(timing.URL, "/echo") {
+		t.Fatalf("expected timing of the final exchange, got %+v", timing)
+	}
+	if timing.Redirects != 1 || timing.RedirectTime <= 0 {
+		t.Errorf("expected one redirect hop, got %d %s", timing.Redirects, timing.RedirectTime)
+	}
+	if timing.TimeToFirstByte <= 0 || timing.Total < timing.TimeToFirstByte {
+		t.Errorf("expected ttfb within total, got %s %s", timing.TimeToFirstByte, timing.Total)
+	}
+
+	// hooks fire once per request, not per hop
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || span.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("expected traceparent continuation, got %s %s", span.TraceID, span.ParentSpanID)
+	}
+	if span.Attributes["http.response.status_code"] != 200 || len(span.Events) == 0 {
+		t.Errorf("unexpected span %+v", span)
+	}
+
+	var out bytes.Buffer
+	metrics.WritePrometheus(&out)
+	for _, expected := range []string{
+		`goweb_request_duration_seconds_count{phase="total"} 1`,
+		`goweb_request_duration_seconds_bucket{phase="redirect",le="+Inf"} 1`,
+		`goweb_requests_total{code="200"} 1`,
+		`goweb_request_errors_total 0`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %s in\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestRequestTimingError(t *testing.T) {
+	metrics := NewTimingMetrics()
+
+	x := NewHTTP()
+	x.TimingHooks = []TimingHook{metrics}
+	x.Get("http://127.0.0.1:1/")
+
+	if x.Timing() == nil || x.Timing().Err == nil {
+		t.Fatalf("expected a failed timing, got %+v", x.Timing())
+	}
+	if metrics.errors != 1 {
+		t.Errorf("expected 1 error, got %d", metrics.errors)
+	}
+}
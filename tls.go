@@ -0,0 +1,193 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"strings"
+)
+
+// ErrTLSPinMismatch is returned when no peer certificate matches the host pins
+var ErrTLSPinMismatch = errors.New("goweb: TLS SPKI pin mismatch")
+
+//
+// TLSConfig def
+//
+type TLSConfig struct {
+	RootCAs            *x509.CertPool
+	Certificates       []tls.Certificate
+	MinVersion         uint16
+	CipherSuites       []uint16
+	ServerName         string
+	InsecureSkipVerify bool
+	// Pins maps a host, as dialed or as ServerName, to base64 SHA-256 SPKI digests
+	Pins map[string][]string
+}
+
+//
+// NewTLSConfig constructor
+//
+func NewTLSConfig() *TLSConfig {
+	return &TLSConfig{Pins: make(map[string][]string)}
+}
+
+//
+// AddRootCAPEM : Append PEM encoded certificates to the root CA pool
+//
+func (id *TLSConfig) AddRootCAPEM(pemBytes []byte) error {
+	if id.RootCAs == nil {
+		id.RootCAs = x509.NewCertPool()
+	}
+
+	if !id.RootCAs.AppendCertsFromPEM(pemBytes) {
+		return errors.New("goweb: no certificates found in PEM data")
+	}
+
+	return nil
+}
+
+//
+// AddRootCAFile : Append the PEM encoded certificates in path to the root CA pool
+//
+func (id *TLSConfig) AddRootCAFile(path string) error {
+	pemBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return id.AddRootCAPEM(pemBytes)
+}
+
+//
+// AddClientCertificateFile : Load a client certificate / key pair
+//
+func (id *TLSConfig) AddClientCertificateFile(certFile string, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	id.Certificates = append(id.Certificates, cert)
+
+	return nil
+}
+
+//
+// AddPin : Pin host to the base64 SHA-256 digest of a certificate public key
+//
+func (id *TLSConfig) AddPin(host string, pin string) {
+	if id.Pins == nil {
+		id.Pins = make(map[string][]string)
+	}
+
+	host = strings.ToLower(host)
+	id.Pins[host] = append(id.Pins[host], strings.TrimPrefix(pin, "sha256/"))
+}
+
+//
+// Config : The crypto/tls configuration equivalent
+// Without the dialed host, pins are looked up by ServerName or the SNI name.
+//
+func (id *TLSConfig) Config() *tls.Config {
+	return id.ConfigForHost("")
+}
+
+//
+// ConfigForHost : The crypto/tls configuration for a connection dialed to host
+//
+func (id *TLSConfig) ConfigForHost(host string) *tls.Config {
+	config := &tls.Config{
+		RootCAs:            id.RootCAs,
+		Certificates:       id.Certificates,
+		MinVersion:         id.MinVersion,
+		CipherSuites:       id.CipherSuites,
+		ServerName:         id.ServerName,
+		InsecureSkipVerify: id.InsecureSkipVerify,
+	}
+
+	if len(id.Pins) > 0 {
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			return id.verifyPins(host, state)
+		}
+	}
+
+	return config
+}
+
+//
+// TLS: Verify the peer chain against the pins of the dialed host, else of the server name
+// Hosts without pins rely on the chain verification alone.
+//
+func (id *TLSConfig) verifyPins(host string, state tls.ConnectionState) error {
+	var pins []string
+	for _, name := range []string{host, id.ServerName, state.ServerName} {
+		if len(name) > 0 {
+			if pins = id.Pins[strings.ToLower(name)]; len(pins) > 0 {
+				break
+			}
+		}
+	}
+	if len(pins) == 0 {
+		return nil
+	}
+
+	for _, cert := range state.PeerCertificates {
+		fingerprint := SPKIFingerprint(cert)
+		for _, pin := range pins {
+			if pin == fingerprint {
+				return nil
+			}
+		}
+	}
+
+	return ErrTLSPinMismatch
+}
+
+//
+// SPKIFingerprint : base64 SHA-256 digest of the certificate SubjectPublicKeyInfo
+//
+func SPKIFingerprint(cert *x509.Certificate) string {
+	digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(digest[:])
+}
+
+//
+// TLSState def
+//
+type TLSState struct {
+	Version            uint16
+	VersionName        string
+	CipherSuite        uint16
+	CipherSuiteName    string
+	ServerName         string
+	NegotiatedProtocol string
+	PeerCertificates   []*x509.Certificate
+}
+
+//
+// TLSState : The negotiated TLS state of the last response, nil if plaintext
+//
+func (id *HTTP) TLSState() *TLSState {
+	if id.resp == nil || id.resp.TLS == nil {
+		return nil
+	}
+
+	state := id.resp.TLS
+
+	return &TLSState{
+		Version:            state.Version,
+		VersionName:        tls.VersionName(state.Version),
+		CipherSuite:        state.CipherSuite,
+		CipherSuiteName:    tls.CipherSuiteName(state.CipherSuite),
+		ServerName:         state.ServerName,
+		NegotiatedProtocol: state.NegotiatedProtocol,
+		PeerCertificates:   state.PeerCertificates,
+	}
+}
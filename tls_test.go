@@ -0,0 +1,91 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTLSTestServer(t *testing.T) (*httptest.Server, *TLSConfig) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "secure")
+	}))
+
+	config := NewTLSConfig()
+	config.RootCAs = server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	config.ServerName = "example.com"
+
+	return server, config
+}
+
+func TestTLSPin(t *testing.T) {
+	server, config := newTLSTestServer(t)
+	defer server.Close()
+
+	config.AddPin("example.com", SPKIFingerprint(server.Certificate()))
+
+	x := NewHTTP()
+	x.TLS = config
+	x.Get(server.URL)
+	if x.Contents() != "secure" {
+		t.Errorf("TLS request failed [%s] %v", x.Contents(), x.LastError())
+	}
+
+	state := x.TLSState()
+	if state == nil || state.ServerName != "example.com" || len(state.PeerCertificates) == 0 {
+		t.Errorf("TLS state not available %v", state)
+	}
+}
+
+func TestTLSPinMismatch(t *testing.T) {
+	server, config := newTLSTestServer(t)
+	defer server.Close()
+
+	config.AddPin("example.com", "sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+
+	x := NewHTTP()
+	x.TLS = config
+	x.Get(server.URL)
+	if !errors.Is(x.LastError(), ErrTLSPinMismatch) {
+		t.Errorf("TLS pin mismatch not detected %v", x.LastError())
+	}
+}
+
+func TestTLSPinDialedHost(t *testing.T) {
+	server, config := newTLSTestServer(t)
+	defer server.Close()
+
+	// no SNI is sent to an IP literal, the pin is found by the dialed host
+	config.ServerName = ""
+	config.AddPin("127.0.0.1", SPKIFingerprint(server.Certificate()))
+
+	x := NewHTTP()
+	x.TLS = config
+	x.Get(server.URL)
+	if x.Contents() != "secure" {
+		t.Errorf("TLS request failed [%s] %v", x.Contents(), x.LastError())
+	}
+}
+
+func TestTLSPinUnpinnedHost(t *testing.T) {
+	server, config := newTLSTestServer(t)
+	defer server.Close()
+
+	// pins for another host leave this one to the chain verification
+	config.ServerName = ""
+	config.AddPin("example.com", "sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+
+	x := NewHTTP()
+	x.TLS = config
+	x.Get(server.URL)
+	if x.Contents() != "secure" {
+		t.Errorf("unpinned host rejected [%s] %v", x.Contents(), x.LastError())
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// UNMARSHAL_TEXT targets the collapsed reader text of the match, the default
+	UNMARSHAL_TEXT = "text"
+	// UNMARSHAL_HTML targets the inner HTML of the match
+	UNMARSHAL_HTML = "html"
+)
+
+var (
+	// ErrUnmarshalTarget is returned when Unmarshal is not given a pointer to a struct
+	ErrUnmarshalTarget = errors.New("goweb: unmarshal target must be a pointer to a struct")
+	// ErrSelectorNoMatch is the cause reported for a required field that matched nothing
+	ErrSelectorNoMatch = errors.New("goweb: selector matched nothing")
+	// ErrSelectorSyntax is returned for a tag whose attribute brackets are left open
+	ErrSelectorSyntax = errors.New("goweb: malformed selector")
+)
+
+//
+// UnmarshalError def
+// The field that could not be filled and why.
+//
+type UnmarshalError struct {
+	Field    string
+	Selector string
+	Err      error
+}
+
+func (id *UnmarshalError) Error() string {
+	return "goweb: unmarshal " + id.Field + " (" + id.Selector + "): " + id.Err.Error()
+}
+
+func (id *UnmarshalError) Unwrap() error {
+	return id.Err
+}
+
+//
+// Unmarshal : Fill the struct v from the document, field by field
+// Each field is tagged with a selector, `goweb:"span.price"`, and optionally
+// the target of the match after an @, `goweb:"a.next@href"`, where @text is
+// the default and @html the inner HTML. A ",required" suffix fails the
+// unmarshal when nothing matches, other fields keep their zero value.
+// Slices take every match, structs are filled from within the first match,
+// or the current scope when the selector is empty, and slices of structs
+// from within each match. Text converts to strings, booleans, numbers and
+// times, a failed conversion is reported as an UnmarshalError.
+//
+func (id *DOM) Unmarshal(v interface{}) error {
+	return id.ChildUnmarshal(id.RootNode(), v)
+}
+
+//
+// ChildUnmarshal : Fill the struct v from the descendants of parent
+//
+func (id *DOM) ChildUnmarshal(parent *DOMNode, v interface{}) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Ptr || target.IsNil() || target.Elem().Kind() != reflect.Struct {
+		return ErrUnmarshalTarget
+	}
+
+	return id._unmarshalStruct(parent, target.Elem(), "")
+}
+
+//
+// Unmarshal: Fill the tagged fields of a struct from within scope
+//
+func (id *DOM) _unmarshalStruct(scope *DOMNode, value reflect.Value, path string) error {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		tag, ok := field.Tag.Lookup("goweb")
+		if !ok || tag == "-" || len(field.PkgPath) > 0 {
+			continue
+		}
+
+		name := path + field.Name
+
+		// attribute values may hold , or @, the options and target follow the last ]
+		tail := strings.LastIndex(tag, "]") + 1
+		if strings.LastIndex(tag, "[") >= tail {
+			return &UnmarshalError{Field: name, Selector: tag, Err: ErrSelectorSyntax}
+		}
+
+		required := false
+		if idx := strings.Index(tag[tail:], ","); idx > -1 {
+			for _, option := range strings.Split(tag[tail+idx+1:], ",") {
+				if strings.TrimSpace(option) == "required" {
+					required = true
+				}
+			}
+			tag = tag[:tail+idx]
+		}
+
+		selector, targetName := tag, UNMARSHAL_TEXT
+		if idx := strings.LastIndex(tag[tail:], "@"); idx > -1 {
+			selector, targetName = tag[:tail+idx], strings.ToLower(tag[tail+idx+1:])
+		}
+		selector = strings.TrimSpace(selector)
+
+		if err := id._unmarshalField(scope, value.Field(i), selector, targetName, required, name); err != nil {
+			if _, ok := err.(*UnmarshalError); ok {
+				return err
+			}
+			return &UnmarshalError{Field: name, Selector: tag, Err: err}
+		}
+	}
+
+	return nil
+}
+
+//
+// Unmarshal: Fill one field from the matches of its selector
+//
+func (id *DOM) _unmarshalField(scope *DOMNode, field reflect.Value, selector string, targetName string, required bool, name string) error {
+	matches := []*DOMNode{scope}
+	if len(selector) > 0 {
+		matches = id.ChildSelect(scope, selector)
+	}
+	if len(matches) == 0 || matches[0] == nil {
+		if required {
+			return ErrSelectorNoMatch
+		}
+		return nil
+	}
+
+	fieldType := field.Type()
+	switch {
+	case fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() != reflect.Uint8:
+		slice := reflect.MakeSlice(fieldType, 0, len(matches))
+		for i, node := range matches {
+			item := reflect.New(fieldType.Elem()).Elem()
+			if err := id._unmarshalValue(node, item, targetName, name+"["+strconv.Itoa(i)+"]."); err != nil {
+				return err
+			}
+			slice = reflect.Append(slice, item)
+		}
+		field.Set(slice)
+		return nil
+	default:
+		return id._unmarshalValue(matches[0], field, targetName, name+".")
+	}
+}
+
+//
+// Unmarshal: Fill a struct from within node, or convert the target text of node
+//
+func (id *DOM) _unmarshalValue(node *DOMNode, value reflect.Value, targetName string, path string) error {
+	if value.Kind() == reflect.Struct && value.Type() != reflect.TypeOf(time.Time{}) {
+		return id._unmarshalStruct(node, value, path)
+	}
+	if value.Kind() == reflect.Ptr && value.Type().Elem().Kind() == reflect.Struct && value.Type().Elem() != reflect.TypeOf(time.Time{}) {
+		item := reflect.New(value.Type().Elem())
+		if err := id._unmarshalStruct(node, item.Elem(), path); err != nil {
+			return err
+		}
+		value.Set(item)
+		return nil
+	}
+
+	var text string
+	switch targetName {
+	case UNMARSHAL_TEXT:
+		text = strings.Join(strings.Fields(node.ReaderText()), " ")
+	case UNMARSHAL_HTML:
+		text = node.InnerHTML()
+	default:
+		text = node.Attr(targetName)
+	}
+
+	return _setFieldValue(value, text)
+}
+
+//
+// Set a field from text, times parsed in the sitemap formats
+//
+func _setFieldValue(field reflect.Value, text string) error {
+	switch {
+	case field.Type() == reflect.TypeOf(time.Time{}):
+		if text = strings.TrimSpace(text); len(text) == 0 {
+			return nil
+		}
+		parsed, err := ParseSitemapTime(text)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(parsed))
+		return nil
+	case field.Kind() == reflect.Ptr && field.Type().Elem() == reflect.TypeOf(time.Time{}):
+		item := reflect.New(field.Type().Elem())
+		if len(strings.TrimSpace(text)) == 0 {
+			return nil
+		}
+		if err := _setFieldValue(item.Elem(), text); err != nil {
+			return err
+		}
+		field.Set(item)
+		return nil
+	}
+
+	return _setFieldText(field, text)
+}
@@ -0,0 +1,145 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const _unmarshalTestPage = `<html><body>
+<div class="product featured" id="p1">
+  <h1 class="title"> Canoe  </h1>
+  <span class="price">1,299.50</span>
+  <span class="stock">yes</span>
+  <time datetime="2026-05-01">May 1</time>
+  <a class="next" href="/p2">Next</a>
+  <ul class="tags"><li>boats</li><li>outdoor</li></ul>
+  <div class="review"><b>Ann</b> <span class="stars">5</span></div>
+  <div class="review"><b>Bo</b> <span class="stars">3</span></div>
+</div>
+<span class="price">0</span>
+</body></html>`
+
+func TestSelect(t *testing.T) {
+	d := NewDOM()
+	d.SetContents(_unmarshalTestPage)
+
+	if nodes := d.Select("div.product span.price"); len(nodes) != 1 || nodes[0].Text() != "1,299.50" {
+		t.Errorf("unexpected matches %v", nodes)
+	}
+	if nodes := d.Select(".price"); len(nodes) != 2 {
+		t.Errorf("expected 2 prices, got %d", len(nodes))
+	}
+	if nodes := d.Select("#p1 a[href=/p2]"); len(nodes) != 1 {
+		t.Errorf("expected the next link, got %d", len(nodes))
+	}
+	if nodes := d.Select("div.review b"); len(nodes) != 2 || nodes[0].Text() != "Ann" {
+		t.Errorf("unexpected reviewers %v", nodes)
+	}
+}
+
+func TestSelectQuotedAttribute(t *testing.T) {
+	d := NewDOM()
+	d.SetContents(`<html><body><nav><a title="Next page" href="/p2">next</a><a title="a ] b" href="/x">x</a><a title="Next">n</a></nav></body></html>`)
+
+	// whitespace and brackets inside the quotes belong to the value
+	if nodes := d.Select(`nav a[title="Next page"]`); len(nodes) != 1 || nodes[0].Attr("href") != "/p2" {
+		t.Errorf("unexpected matches %v", nodes)
+	}
+	if nodes := d.Select(`a[title='a ] b'][href]`); len(nodes) != 1 || nodes[0].Attr("href") != "/x" {
+		t.Errorf("unexpected matches %v", nodes)
+	}
+	if nodes := d.Select(`a[title="Next`); nodes != nil {
+		t.Errorf("expected no match for an open bracket, got %v", nodes)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	d := NewDOM()
+	d.SetContents(_unmarshalTestPage)
+
+	type review struct {
+		Author string `goweb:"b"`
+		Stars  int    `goweb:"span.stars"`
+	}
+	type product struct {
+		Name      string    `goweb:"h1.title,required"`
+		Price     float64   `goweb:"div.product span.price"`
+		InStock   bool      `goweb:"span.stock"`
+		Added     time.Time `goweb:"time@datetime"`
+		Next      string    `goweb:"a.next@href"`
+		Tags      []string  `goweb:"ul.tags li"`
+		Reviews   []review  `goweb:"div.review"`
+		Top       *review   `goweb:"div.review"`
+		Missing   *int      `goweb:"span.missing"`
+		Untouched string
+	}
+
+	p := product{}
+	if err := d.Unmarshal(&p); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Name != "Canoe" || p.Price != 1299.5 || !p.InStock || p.Next != "/p2" {
+		t.Errorf("unexpected product %+v", p)
+	}
+	if !p.Added.Equal(time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected date %s", p.Added)
+	}
+	if len(p.Tags) != 2 || p.Tags[1] != "outdoor" {
+		t.Errorf("unexpected tags %q", p.Tags)
+	}
+	if len(p.Reviews) != 2 || p.Reviews[1].Author != "Bo" || p.Reviews[1].Stars != 3 {
+		t.Errorf("unexpected reviews %+v", p.Reviews)
+	}
+	if p.Top == nil || p.Top.Author != "Ann" || p.Missing != nil {
+		t.Errorf("unexpected pointers %+v %v", p.Top, p.Missing)
+	}
+
+	required := struct {
+		Missing string `goweb:"span.missing,required"`
+	}{}
+	err := d.Unmarshal(&required)
+	if !errors.Is(err, ErrSelectorNoMatch) {
+		t.Errorf("expected ErrSelectorNoMatch, got %v", err)
+	}
+
+	invalid := struct {
+		Reviews []struct {
+			Author int `goweb:"b"`
+		} `goweb:"div.review"`
+	}{}
+	err = d.Unmarshal(&invalid)
+	if unmarshalErr, ok := err.(*UnmarshalError); !ok || unmarshalErr.Field != "Reviews[0].Author" {
+		t.Errorf("expected an UnmarshalError on Reviews[0].Author, got %v", err)
+	}
+
+	if err := d.Unmarshal(p); err != ErrUnmarshalTarget {
+		t.Errorf("expected ErrUnmarshalTarget, got %v", err)
+	}
+}
+
+func TestUnmarshalAttributeSelector(t *testing.T) {
+	d := NewDOM()
+	d.SetContents(`<html><body><a href="mailto:team@example.com" title="a,b">Mail</a></body></html>`)
+
+	// the @ and , inside the brackets belong to the selector
+	var contact struct {
+		Label string `goweb:"a[href=mailto:team@example.com]"`
+		Title string `goweb:"a[title=a,b]@title,required"`
+	}
+	if err := d.Unmarshal(&contact); err != nil || contact.Label != "Mail" || contact.Title != "a,b" {
+		t.Errorf("unexpected contact %+v %v", contact, err)
+	}
+
+	var broken struct {
+		Label string `goweb:"a[href=mailto:team@example.com"`
+	}
+	if err := d.Unmarshal(&broken); !errors.Is(err, ErrSelectorSyntax) {
+		t.Errorf("expected ErrSelectorSyntax, got %v", err)
+	}
+}
@@ -0,0 +1,645 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocket opcodes (RFC 6455 5.2)
+const (
+	WS_CONTINUATION = 0x0
+	WS_TEXT         = 0x1
+	WS_BINARY       = 0x2
+	WS_CLOSE        = 0x8
+	WS_PING         = 0x9
+	WS_PONG         = 0xa
+)
+
+// WebSocket close codes (RFC 6455 7.4.1)
+const (
+	WS_CLOSE_NORMAL         = 1000
+	WS_CLOSE_GOING_AWAY     = 1001
+	WS_CLOSE_PROTOCOL_ERROR = 1002
+	WS_CLOSE_NO_STATUS      = 1005
+	WS_CLOSE_TOO_BIG        = 1009
+)
+
+// the close payload is limited to 125 bytes, two of them carry the code (RFC 6455 5.5)
+const WS_MAX_CLOSE_REASON = 123
+
+const _webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// the trailing empty stored block stripped from each compressed message (RFC 7692 7.2.1)
+var _deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+var (
+	// ErrWebSocketHandshake is returned when the server refuses the upgrade
+	ErrWebSocketHandshake = errors.New("goweb: websocket handshake failed")
+	// ErrWebSocketProtocol is returned for malformed frames
+	ErrWebSocketProtocol = errors.New("goweb: websocket protocol error")
+	// ErrWebSocketTooBig is returned when a message exceeds MaxMessageSize
+	ErrWebSocketTooBig = errors.New("goweb: websocket message too big")
+	// ErrWebSocketClosed is returned when writing after the close frame was sent
+	ErrWebSocketClosed = errors.New("goweb: websocket closed")
+)
+
+//
+// WebSocketCloseError is returned by ReadMessage once the peer closes
+//
+type WebSocketCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (id *WebSocketCloseError) Error() string {
+	return fmt.Sprintf("goweb: websocket closed %d %s", id.Code, id.Reason)
+}
+
+//
+// WebSocketOptions def
+//
+type WebSocketOptions struct {
+	Origin      string
+	Protocols   []string
+	Compression bool
+}
+
+//
+// WebSocket def
+// One goroutine may read while others write, writes are serialized.
+//
+type WebSocket struct {
+	Subprotocol    string
+	MaxMessageSize int64
+	PongHandler    func(data []byte)
+
+	conn      net.Conn
+	reader    *bufio.Reader
+	client    bool
+	compress  bool
+	takeover  bool
+	history   []byte
+	closeSent bool
+	writeLock sync.Mutex
+}
+
+//
+// WebSocket constructor over an established connection
+// client masks outgoing frames, takeover keeps the inbound deflate window between messages.
+//
+func newWebSocket(conn net.Conn, reader *bufio.Reader, client bool, compress bool, takeover bool) *WebSocket {
+	if reader == nil {
+		reader = bufio.NewReader(conn)
+	}
+
+	return &WebSocket{conn: conn, reader: reader, client: client, compress: compress, takeover: takeover, MaxMessageSize: 32 << 20}
+}
+
+//
+// WebSocket : Open a WebSocket sharing the cookies, headers, proxy and TLS settings of the HTTP
+//
+func (id *HTTP) WebSocket(urlString string, options *WebSocketOptions) (ws *WebSocket, err error) {
+	if options == nil {
+		options = &WebSocketOptions{}
+	}
+
+	target, err := url.Parse(urlString)
+	if err != nil {
+		return nil, err
+	}
+
+	// the equivalent http URL drives cookies, TLS and the request line
+	httpURL := *target
+	switch strings.ToLower(target.Scheme) {
+	case "ws":
+		httpURL.Scheme = "http"
+	case "wss":
+		httpURL.Scheme = "https"
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("goweb: unsupported websocket scheme %s", target.Scheme)
+	}
+
+	conn, err := id.dialWebSocket(&httpURL)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := id.streamRequest(HTTP_GET, httpURL.String())
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if len(options.Origin) > 0 {
+		req.Header.Set("Origin", options.Origin)
+	}
+	if len(options.Protocols) > 0 {
+		req.Header.Set("Sec-WebSocket-Protocol", strings.Join(options.Protocols, ", "))
+	}
+	if options.Compression {
+		// a fresh compressor per message, so the server needs no window of ours
+		req.Header.Set("Sec-WebSocket-Extensions", "permessage-deflate; client_no_context_takeover")
+	}
+	if id.cookieJar != nil {
+		for _, cookie := range id.cookieJar.Cookies(&httpURL) {
+			req.AddCookie(cookie)
+		}
+	}
+
+	headerTimeout := id.timeouts().Header
+	if headerTimeout <= 0 {
+		headerTimeout = HTTP_DEFAULT_TIMEOUT
+	}
+	conn.SetDeadline(time.Now().Add(headerTimeout))
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	if id.cookieJar != nil {
+		id.cookieJar.SetCookies(&httpURL, resp.Cookies())
+	}
+
+	digest := sha1.Sum([]byte(key + _webSocketGUID))
+	accept := base64.StdEncoding.EncodeToString(digest[:])
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		!strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") ||
+		!_headerHasToken(resp.Header, "Connection", "upgrade") ||
+		resp.Header.Get("Sec-WebSocket-Accept") != accept {
+		conn.Close()
+		return nil, ErrWebSocketHandshake
+	}
+
+	compress, takeover := false, true
+	extensions := strings.ToLower(resp.Header.Get("Sec-WebSocket-Extensions"))
+	if strings.Contains(extensions, "permessage-deflate") {
+		if !options.Compression {
+			conn.Close()
+			return nil, ErrWebSocketHandshake
+		}
+		compress = true
+		takeover = !strings.Contains(extensions, "server_no_context_takeover")
+	}
+
+	ws = newWebSocket(conn, reader, true, compress, takeover)
+	ws.Subprotocol = resp.Header.Get("Sec-WebSocket-Protocol")
+
+	return ws, nil
+}
+
+//
+// WebSocket: Dial the host, tunneling through the proxy and negotiating TLS as configured
+//
+func (id *HTTP) dialWebSocket(target *url.URL) (conn net.Conn, err error) {
+	host := target.Host
+	if len(target.Port()) == 0 {
+		if target.Scheme == "https" {
+			host = net.JoinHostPort(target.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(target.Hostname(), "80")
+		}
+	}
+
+	timeouts := id.timeouts()
+	connectTimeout := timeouts.Connect
+	if connectTimeout <= 0 {
+		connectTimeout = HTTP_DEFAULT_TIMEOUT
+	}
+
+	proxying := DetectProxy()
+	if proxying {
+		id.ProxyURL, _ = url.Parse("http://127.0.0.1:8080")
+		conn, err = net.DialTimeout("tcp", id.ProxyURL.Host, connectTimeout)
+		if err != nil {
+			return nil, err
+		}
+		connect := &http.Request{Method: "CONNECT", URL: &url.URL{Opaque: host}, Host: host, Header: make(http.Header)}
+		if err = connect.Write(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(conn), connect)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("goweb: proxy CONNECT failed %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			conn.Close()
+			return nil, fmt.Errorf("goweb: proxy CONNECT status %d", resp.StatusCode)
+		}
+	} else {
+		conn, err = net.DialTimeout("tcp", host, connectTimeout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if target.Scheme != "https" {
+		return conn, nil
+	}
+
+	var config *tls.Config
+	if id.TLS != nil {
+		config = id.TLS.ConfigForHost(target.Hostname())
+	} else {
+		config = &tls.Config{}
+	}
+	if len(config.ServerName) == 0 {
+		config.ServerName = target.Hostname()
+	}
+	// if we're proxying, we're going to disable the TLS cert verification
+	if proxying {
+		config.InsecureSkipVerify = true
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if timeouts.TLS > 0 {
+		tlsConn.SetDeadline(time.Now().Add(timeouts.TLS))
+	}
+	if err = tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn.SetDeadline(time.Time{})
+
+	return tlsConn, nil
+}
+
+//
+// ReadMessage : The next text or binary message, control frames are handled inline
+//
+func (id *WebSocket) ReadMessage() (opcode int, data []byte, err error) {
+	var message []byte
+	compressed := false
+
+	for {
+		fin, rsv1, frameOpcode, payload, err := id.readFrame()
+		if err != nil {
+			id.writeLock.Lock()
+			closing := id.closeSent
+			id.writeLock.Unlock()
+			if closing {
+				// the peer never answered our close frame
+				id.conn.Close()
+			}
+			return 0, nil, err
+		}
+
+		switch frameOpcode {
+		case WS_PING:
+			if err = id.writeFrame(true, false, WS_PONG, payload); err != nil && err != ErrWebSocketClosed {
+				return 0, nil, err
+			}
+			continue
+		case WS_PONG:
+			if id.PongHandler != nil {
+				id.PongHandler(payload)
+			}
+			continue
+		case WS_CLOSE:
+			if len(payload) == 1 {
+				id.Close(WS_CLOSE_PROTOCOL_ERROR, "")
+				return 0, nil, ErrWebSocketProtocol
+			}
+			// echo the close unless this answers our own, 1005 is never sent so an empty close gets an empty echo
+			closeErr := &WebSocketCloseError{Code: WS_CLOSE_NO_STATUS}
+			if len(payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Reason = string(payload[2:])
+				id.sendClose(closeErr.Code, "")
+			} else {
+				id.writeFrame(true, false, WS_CLOSE, nil)
+			}
+			id.conn.Close()
+			return 0, nil, closeErr
+		case WS_TEXT, WS_BINARY:
+			if message != nil {
+				return 0, nil, ErrWebSocketProtocol
+			}
+			opcode = frameOpcode
+			compressed = rsv1 && id.compress
+			message = []byte{}
+		case WS_CONTINUATION:
+			if message == nil {
+				return 0, nil, ErrWebSocketProtocol
+			}
+		default:
+			return 0, nil, ErrWebSocketProtocol
+		}
+
+		message = append(message, payload...)
+		if int64(len(message)) > id.MaxMessageSize {
+			id.Close(WS_CLOSE_TOO_BIG, "")
+			return 0, nil, ErrWebSocketTooBig
+		}
+
+		if fin {
+			if compressed {
+				message, err = id.inflate(message)
+				if err == ErrWebSocketTooBig {
+					id.Close(WS_CLOSE_TOO_BIG, "")
+				}
+			}
+			return opcode, message, err
+		}
+	}
+}
+
+//
+// WriteMessage : Send a text or binary message, compressed if negotiated
+//
+func (id *WebSocket) WriteMessage(opcode int, data []byte) error {
+	rsv1 := false
+	if id.compress && (opcode == WS_TEXT || opcode == WS_BINARY) {
+		compressed, err := _deflate(data)
+		if err != nil {
+			return err
+		}
+		data = compressed
+		rsv1 = true
+	}
+
+	return id.writeFrame(true, rsv1, opcode, data)
+}
+
+//
+// WriteText : Send a text message
+//
+func (id *WebSocket) WriteText(text string) error {
+	return id.WriteMessage(WS_TEXT, []byte(text))
+}
+
+//
+// Ping : Send a ping, the pong is delivered to PongHandler by ReadMessage
+//
+func (id *WebSocket) Ping(data []byte) error {
+	return id.writeFrame(true, false, WS_PING, data)
+}
+
+//
+// Close : Start the closing handshake, ReadMessage completes it and closes the connection
+// The peer has two seconds to answer before ReadMessage gives up.
+//
+func (id *WebSocket) Close(code int, reason string) error {
+	err := id.sendClose(code, reason)
+	if err == ErrWebSocketClosed {
+		return nil
+	}
+	if err != nil {
+		id.conn.Close()
+		return err
+	}
+
+	return id.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+}
+
+//
+// WebSocket: Send a close frame, the reason is cut to fit on a rune boundary
+//
+func (id *WebSocket) sendClose(code int, reason string) error {
+	if len(reason) > WS_MAX_CLOSE_REASON {
+		cut := WS_MAX_CLOSE_REASON
+		for cut > 0 && !utf8.RuneStart(reason[cut]) {
+			cut--
+		}
+		reason = reason[:cut]
+	}
+
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+
+	return id.writeFrame(true, false, WS_CLOSE, payload)
+}
+
+//
+// WebSocket: Read one frame, unmasking it if required
+//
+func (id *WebSocket) readFrame() (fin bool, rsv1 bool, opcode int, payload []byte, err error) {
+	header := make([]byte, 2)
+	if _, err = io.ReadFull(id.reader, header); err != nil {
+		return
+	}
+
+	fin = header[0]&0x80 != 0
+	rsv1 = header[0]&0x40 != 0
+	opcode = int(header[0] & 0x0f)
+	masked := header[1]&0x80 != 0
+	length := int64(header[1] & 0x7f)
+
+	// RSV1 only marks the first frame of a compressed message, RSV2 and RSV3 are never negotiated
+	if header[0]&0x30 != 0 || (rsv1 && (!id.compress || opcode == WS_CONTINUATION || opcode >= WS_CLOSE)) {
+		err = ErrWebSocketProtocol
+		return
+	}
+
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		if _, err = io.ReadFull(id.reader, extended); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		if _, err = io.ReadFull(id.reader, extended); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint64(extended))
+	}
+
+	if length < 0 || length > id.MaxMessageSize {
+		err = ErrWebSocketTooBig
+		return
+	}
+	if opcode >= WS_CLOSE && (!fin || length > 125) {
+		// control frames are never fragmented and carry at most 125 bytes
+		err = ErrWebSocketProtocol
+		return
+	}
+
+	var mask []byte
+	if masked {
+		mask = make([]byte, 4)
+		if _, err = io.ReadFull(id.reader, mask); err != nil {
+			return
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(id.reader, payload); err != nil {
+		return
+	}
+
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	return
+}
+
+//
+// WebSocket: Write one frame, clients mask every frame (RFC 6455 5.3)
+// Nothing follows the close frame.
+//
+func (id *WebSocket) writeFrame(fin bool, rsv1 bool, opcode int, payload []byte) error {
+	header := []byte{byte(opcode), 0}
+	if fin {
+		header[0] |= 0x80
+	}
+	if rsv1 {
+		header[0] |= 0x40
+	}
+
+	length := len(payload)
+	switch {
+	case length <= 125:
+		header[1] = byte(length)
+	case length <= 0xffff:
+		header[1] = 126
+		header = append(header, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header[1] = 127
+		header = append(header, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+
+	frame := payload
+	if id.client {
+		header[1] |= 0x80
+		mask := make([]byte, 4)
+		rand.Read(mask)
+		header = append(header, mask...)
+		frame = make([]byte, length)
+		for i := range payload {
+			frame[i] = payload[i] ^ mask[i%4]
+		}
+	}
+
+	id.writeLock.Lock()
+	defer id.writeLock.Unlock()
+
+	if id.closeSent {
+		return ErrWebSocketClosed
+	}
+	if opcode == WS_CLOSE {
+		id.closeSent = true
+	}
+
+	if _, err := id.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := id.conn.Write(frame)
+
+	return err
+}
+
+//
+// WebSocket: Compress a message payload (RFC 7692 7.2.1)
+//
+func _deflate(data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer, err := flate.NewWriter(&buffer, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err = writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buffer.Bytes(), _deflateTail), nil
+}
+
+//
+// WebSocket: Decompress a message payload, carrying the window over when the peer takes over context
+//
+func (id *WebSocket) inflate(data []byte) ([]byte, error) {
+	// restore the sync flush tail plus a final empty block so the reader sees EOF
+	data = append(data, _deflateTail...)
+	data = append(data, 0x01, 0x00, 0x00, 0xff, 0xff)
+
+	var reader io.ReadCloser
+	if id.takeover {
+		reader = flate.NewReaderDict(bytes.NewReader(data), id.history)
+	} else {
+		reader = flate.NewReader(bytes.NewReader(data))
+	}
+	defer reader.Close()
+
+	// a small payload can inflate to anything, MaxMessageSize bounds the result too
+	message, err := ioutil.ReadAll(io.LimitReader(reader, id.MaxMessageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(message)) > id.MaxMessageSize {
+		return nil, ErrWebSocketTooBig
+	}
+
+	if id.takeover {
+		id.history = append(id.history, message...)
+		if len(id.history) > 32768 {
+			id.history = id.history[len(id.history)-32768:]
+		}
+	}
+
+	return message, nil
+}
+
+//
+// WebSocket: Whether a comma separated header lists the token, case insensitive
+//
+func _headerHasToken(header http.Header, name string, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,231 @@
+// Copyright 2016, Marc Lavergne <mlavergn@gmail.com>. All rights reserved.
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package goweb
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+//
+// an echo server built on the same framing, answering pings and closes inline
+//
+func newWebSocketTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("auth"); err != nil || cookie.Value != "1" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		digest := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + _webSocketGUID))
+		compress := strings.Contains(r.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+		rw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(digest[:]) + "\r\n")
+		if compress {
+			rw.WriteString("Sec-WebSocket-Extensions: permessage-deflate; client_no_context_takeover\r\n")
+		}
+		rw.WriteString("Sec-WebSocket-Protocol: " + r.Header.Get("Sec-WebSocket-Protocol") + "\r\n\r\n")
+		rw.Flush()
+
+		// the client compresses without context takeover
+		ws := newWebSocket(conn, rw.Reader, false, compress, false)
+		for {
+			opcode, data, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			ws.WriteMessage(opcode, data)
+		}
+	}))
+}
+
+func TestWebSocket(t *testing.T) {
+	server := newWebSocketTestServer(t)
+	defer server.Close()
+
+	for _, compression := range []bool{false, true} {
+		x := NewHTTP()
+		serverURL, _ := url.Parse(server.URL)
+		x.cookieJar.SetCookies(serverURL, []*http.Cookie{{Name: "auth", Value: "1"}})
+
+		ws, err := x.WebSocket("ws"+strings.TrimPrefix(server.URL, "http"), &WebSocketOptions{Protocols: []string{"echo"}, Compression: compression})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ws.Subprotocol != "echo" || ws.compress != compression {
+			t.Errorf("expected subprotocol echo and compression %v, got %s %v", compression, ws.Subprotocol, ws.compress)
+		}
+
+		pong := ""
+		ws.PongHandler = func(data []byte) {
+			pong = string(data)
+		}
+		ws.Ping([]byte("hello"))
+
+		// the server compresses each message afresh, the long one outgrows the 32K window
+		messages := []string{"short", strings.Repeat("long message ", 20000), "short"}
+		for _, message := range messages {
+			if err = ws.WriteText(message); err != nil {
+				t.Fatal(err)
+			}
+			opcode, data, err := ws.ReadMessage()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if opcode != WS_TEXT || string(data) != message {
+				t.Errorf("expected echo of %d bytes, got %d", len(message), len(data))
+			}
+		}
+		if pong != "hello" {
+			t.Errorf("expected pong hello, got %s", pong)
+		}
+
+		if err = ws.WriteMessage(WS_BINARY, []byte{0, 1, 2}); err != nil {
+			t.Fatal(err)
+		}
+		opcode, data, _ := ws.ReadMessage()
+		if opcode != WS_BINARY || len(data) != 3 {
+			t.Errorf("expected binary echo, got %d %v", opcode, data)
+		}
+
+		ws.Close(WS_CLOSE_NORMAL, "done")
+	}
+}
+
+func TestWebSocketHandshakeRefused(t *testing.T) {
+	server := newWebSocketTestServer(t)
+	defer server.Close()
+
+	// no auth cookie
+	_, err := NewHTTP().WebSocket("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != ErrWebSocketHandshake {
+		t.Errorf("expected handshake failure, got %v", err)
+	}
+}
+
+func TestWebSocketClose(t *testing.T) {
+	client, server := net.Pipe()
+	ws := newWebSocket(client, nil, true, false, true)
+	peer := newWebSocket(server, nil, false, false, false)
+
+	closed := make(chan error, 1)
+	go func() {
+		_, _, err := peer.ReadMessage()
+		closed <- err
+	}()
+
+	// Close returns once the frame is out, the reader completes the handshake
+	if err := ws.Close(WS_CLOSE_GOING_AWAY, strings.Repeat("é", 100)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteText("late"); err != ErrWebSocketClosed {
+		t.Errorf("expected writes after close to fail, got %v", err)
+	}
+
+	_, _, err := ws.ReadMessage()
+	if closeErr, ok := err.(*WebSocketCloseError); !ok || closeErr.Code != WS_CLOSE_GOING_AWAY {
+		t.Errorf("expected the peer close reply, got %v", err)
+	}
+	peerErr, ok := (<-closed).(*WebSocketCloseError)
+	if !ok || len(peerErr.Reason) != 122 || !utf8.ValidString(peerErr.Reason) {
+		t.Errorf("expected the reason cut on a rune boundary, got %v", peerErr)
+	}
+}
+
+func TestWebSocketReservedBits(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	ws := newWebSocket(client, nil, true, false, true)
+
+	// a compressed frame without negotiated compression
+	go server.Write([]byte{0xc1, 0x01, 'x'})
+	if _, _, err := ws.ReadMessage(); err != ErrWebSocketProtocol {
+		t.Errorf("expected RSV1 rejected, got %v", err)
+	}
+}
+
+func TestWebSocketHandshakeConnection(t *testing.T) {
+	// a 101 without Connection: Upgrade is not a WebSocket
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		digest := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + _webSocketGUID))
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: keep-alive\r\n")
+		rw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(digest[:]) + "\r\n\r\n")
+		rw.Flush()
+	}))
+	defer server.Close()
+
+	_, err := NewHTTP().WebSocket("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != ErrWebSocketHandshake {
+		t.Errorf("expected handshake failure, got %v", err)
+	}
+}
+
+func TestWebSocketCloseEmpty(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	ws := newWebSocket(client, nil, true, false, true)
+
+	closed := make(chan error, 1)
+	go server.Write([]byte{0x88, 0x00})
+	go func() {
+		_, _, err := ws.ReadMessage()
+		closed <- err
+	}()
+
+	// the masked echo carries no payload, never a 1005 code
+	echo := make([]byte, 6)
+	if _, err := io.ReadFull(server, echo); err != nil || echo[0] != 0x88 || echo[1] != 0x80 {
+		t.Errorf("expected an empty close echo, got %v %v", echo, err)
+	}
+	go io.Copy(ioutil.Discard, server)
+	if closeErr, ok := (<-closed).(*WebSocketCloseError); !ok || closeErr.Code != WS_CLOSE_NO_STATUS {
+		t.Errorf("expected close without status, got %v", closeErr)
+	}
+}
+
+func TestWebSocketInflateTooBig(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	ws := newWebSocket(client, nil, true, true, true)
+	ws.MaxMessageSize = 1024
+
+	// a few bytes on the wire inflating far past the limit
+	compressed, _ := _deflate(bytes.Repeat([]byte("a"), 20000))
+	go func() {
+		server.Write(append([]byte{0xc2, byte(len(compressed))}, compressed...))
+		io.Copy(ioutil.Discard, server)
+	}()
+
+	if _, _, err := ws.ReadMessage(); err != ErrWebSocketTooBig {
+		t.Errorf("expected the inflated message rejected, got %v", err)
+	}
+	if len(ws.history) != 0 {
+		t.Errorf("expected nothing kept in the window, got %d bytes", len(ws.history))
+	}
+}